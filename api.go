@@ -0,0 +1,313 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiFlags are the cli flags taking no value
+var apiFlags = map[string]bool{"--fake": true, "--force": true, "--yes": true}
+
+// apiSession is the api-gateway an ernest-cli user is targeting and the
+// jwt it logged in with
+type apiSession struct {
+	URL   string
+	Token string
+}
+
+var apiSessions = make(map[string]*apiSession)
+var apiLock sync.Mutex
+
+var apiClient = &http.Client{Timeout: 30 * time.Second}
+
+func session(usr string) *apiSession {
+	apiLock.Lock()
+	defer apiLock.Unlock()
+
+	s, ok := apiSessions[usr]
+	if !ok {
+		s = &apiSession{URL: ernest_instance}
+		if u := os.Getenv("UAT_API_URL"); u != "" {
+			s.URL = u
+		}
+		apiSessions[usr] = s
+	}
+	return s
+}
+
+// apiAs runs an ernest-cli command for usr calling the api-gateway http api
+// directly, returning the response bodies as the command output
+func apiAs(usr string, cmdArgs ...string) (string, error) {
+	args, flags := parseArgs(cmdArgs)
+	s := session(usr)
+
+	switch arg(args, 0) {
+	case "target":
+		if len(args) > 1 && os.Getenv("UAT_API_URL") == "" {
+			s.URL = args[1]
+		}
+		return "Target set", nil
+	case "login":
+		return s.login(flags["--user"], flags["--password"])
+	}
+
+	switch arg(args, 0) + " " + arg(args, 1) {
+	case "user list":
+		return s.call("GET", "/api/users/", nil)
+	case "user create":
+		return s.call("POST", "/api/users/", map[string]string{"username": arg(args, 2), "password": arg(args, 3)})
+	case "user delete":
+		return s.call("DELETE", "/api/users/"+arg(args, 2), nil)
+	case "group list":
+		return s.call("GET", "/api/groups/", nil)
+	case "group create":
+		return s.call("POST", "/api/groups/", map[string]string{"name": arg(args, 2)})
+	case "group delete":
+		return s.call("DELETE", "/api/groups/"+arg(args, 2), nil)
+	case "group add-user":
+		return s.call("POST", "/api/groups/"+arg(args, 3)+"/users/", map[string]string{"username": arg(args, 2)})
+	case "group remove-user":
+		return s.call("DELETE", "/api/groups/"+arg(args, 3)+"/users/"+arg(args, 2), nil)
+	case "datacenter list":
+		return s.call("GET", "/api/datacenters/", nil)
+	case "datacenter create":
+		return s.call("POST", "/api/datacenters/", datacenterBody(args, flags))
+	case "datacenter update":
+		return s.call("PUT", "/api/datacenters/"+arg(args, 3), datacenterBody(args, flags))
+	case "datacenter delete":
+		return s.call("DELETE", "/api/datacenters/"+arg(args, 2), nil)
+	case "service list":
+		return s.call("GET", "/api/services/", nil)
+	case "service info":
+		return s.call("GET", "/api/services/"+arg(args, 2), nil)
+	case "service definition":
+		return s.definition(arg(args, 2))
+	case "service history":
+		return s.call("GET", "/api/services/"+arg(args, 2)+"/builds/", nil)
+	case "service apply":
+		return s.apply(arg(args, 2))
+	case "service destroy":
+		return s.destroy(arg(args, 2))
+	}
+
+	return "", fmt.Errorf("%s is not supported by the api driver", strings.Join(cmdArgs, " "))
+}
+
+func (s *apiSession) login(usr, pwd string) (string, error) {
+	form := url.Values{"username": {usr}, "password": {pwd}}
+	resp, err := apiClient.PostForm(s.endpoint("/auth"), form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return output(resp, body), nil
+	}
+
+	var auth struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(body, &auth); err != nil {
+		return string(body), err
+	}
+	s.Token = auth.Token
+
+	return "Welcome back " + usr, nil
+}
+
+// apply creates a build of a definition and waits for it to finish, as the
+// cli does
+func (s *apiSession) apply(file string) (string, error) {
+	def, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	o, err := s.request("POST", "/api/services/", "application/yaml", bytes.NewReader(def))
+	if err != nil || !strings.HasPrefix(o, "{") {
+		return o, err
+	}
+
+	return s.wait(definitionName(string(def)), func(status string, found bool) bool {
+		return found && status != "in_progress"
+	})
+}
+
+// destroy deletes a service and waits for it to be gone, as the cli does
+func (s *apiSession) destroy(name string) (string, error) {
+	o, err := s.call("DELETE", "/api/services/"+name, nil)
+	if err != nil || !strings.HasPrefix(o, "{") {
+		return o, err
+	}
+
+	return s.wait(name, func(status string, found bool) bool {
+		return !found || status == "errored"
+	})
+}
+
+// definition returns the definition of the latest build of a service, as
+// the cli prints it
+func (s *apiSession) definition(name string) (string, error) {
+	o, err := s.call("GET", "/api/services/"+name, nil)
+	if err != nil || !strings.HasPrefix(o, "{") {
+		return o, err
+	}
+
+	var svc struct {
+		Definition string `json:"definition"`
+	}
+	if err := json.Unmarshal([]byte(o), &svc); err != nil {
+		return o, err
+	}
+	return svc.Definition, nil
+}
+
+// wait polls a service until done reports it finished
+func (s *apiSession) wait(name string, done func(status string, found bool) bool) (string, error) {
+	timeout := time.After(2 * time.Minute)
+	for {
+		o, err := s.call("GET", "/api/services/"+name, nil)
+		if err != nil {
+			return o, err
+		}
+
+		var svc struct {
+			Status string `json:"status"`
+		}
+		found := json.Unmarshal([]byte(o), &svc) == nil && svc.Status != ""
+		if done(svc.Status, found) {
+			return o, nil
+		}
+
+		select {
+		case <-timeout:
+			return o, errors.New("timeout waiting for service " + name)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func (s *apiSession) call(method, path string, body interface{}) (string, error) {
+	if body == nil {
+		return s.request(method, path, "", nil)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	return s.request(method, path, "application/json", bytes.NewReader(data))
+}
+
+// request calls the api with the session jwt, error responses being
+// returned as the output with their status, as the cli prints them
+func (s *apiSession) request(method, path, contentType string, body io.Reader) (string, error) {
+	req, err := http.NewRequest(method, s.endpoint(path), body)
+	if err != nil {
+		return "", err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	resp, err := apiClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	return output(resp, data), err
+}
+
+func (s *apiSession) endpoint(path string) string {
+	return strings.TrimSuffix(s.URL, "/") + path
+}
+
+func output(resp *http.Response, body []byte) string {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return string(body)
+	}
+	return resp.Status + ": " + string(body)
+}
+
+// datacenterBody maps the datacenter create and update flags to the api
+// fields, the vcloud user being sent as user@org as the cli does
+func datacenterBody(args []string, flags map[string]string) map[string]string {
+	body := map[string]string{
+		"name":             arg(args, 3),
+		"type":             arg(args, 2),
+		"region":           flags["--region"],
+		"username":         flags["--user"],
+		"password":         flags["--password"],
+		"vcloud_url":       flags["--vcloud-url"],
+		"vse_url":          flags["--vse-url"],
+		"external_network": flags["--public-network"],
+		"token":            flags["--token"],
+		"secret":           flags["--secret"],
+	}
+	if flags["--org"] != "" {
+		body["username"] = flags["--user"] + "@" + flags["--org"]
+	}
+	if flags["--fake"] != "" {
+		body["type"] = body["type"] + "-fake"
+	}
+	return body
+}
+
+// definitionName returns the service name of a yaml definition
+func definitionName(def string) string {
+	for _, line := range strings.Split(def, "\n") {
+		if strings.HasPrefix(line, "name:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "name:"))
+		}
+	}
+	return ""
+}
+
+// parseArgs splits cli arguments into positional ones and flags
+func parseArgs(cmdArgs []string) ([]string, map[string]string) {
+	var args []string
+	flags := make(map[string]string)
+
+	for i := 0; i < len(cmdArgs); i++ {
+		a := cmdArgs[i]
+		switch {
+		case !strings.HasPrefix(a, "--"):
+			args = append(args, a)
+		case apiFlags[a] || i+1 == len(cmdArgs):
+			flags[a] = "true"
+		default:
+			flags[a] = cmdArgs[i+1]
+			i++
+		}
+	}
+
+	return args, flags
+}
+
+func arg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
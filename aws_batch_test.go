@@ -0,0 +1,115 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAWSBatches(t *testing.T) {
+	var service = "awsb"
+	service = service + strconv.Itoa(rand.Intn(9999999))
+	service2 := service + "II"
+	service3 := service + "III"
+
+	basicSetup("aws")
+
+	Convey("Given I have a non existing aws definition", t, func() {
+		Convey("When I apply aws1.yml", func() {
+			tl, err := recordTimeline("*.*.aws-fake", "*.*.aws-fake.*")
+			So(err, ShouldBeNil)
+			f := getDefinitionPathAWS("aws1.yml", service)
+			def, err := ioutil.ReadFile(f)
+			So(err, ShouldBeNil)
+			phases := createPhases(string(def), awsCreatePhases)
+
+			_, err = ernest("service", "apply", f)
+			Convey("Then every phase should run in its own batch", func() {
+				if err != nil {
+					log.Println(err.Error())
+				}
+
+				So(tl.Wait("instance.create.aws-fake.done", 1), ShouldBeNil)
+
+				Info("And each request should be correlated with its response", " ", 6)
+				So(tl.CheckBatches(), ShouldBeEmpty)
+
+				Info("And should use one batch per phase", " ", 6)
+				var batched []string
+				for _, entries := range tl.Batches() {
+					batched = append(batched, entries[0].phase())
+				}
+				sort.Strings(batched)
+				So(batched, ShouldResemble, phases)
+			})
+
+			tl.Stop()
+		})
+
+		Convey("When I apply aws1.yml on two services at once", func() {
+			tl, err := recordTimeline("*.*.aws-fake", "*.*.aws-fake.*")
+			So(err, ShouldBeNil)
+
+			var wg sync.WaitGroup
+			for _, s := range []string{service2, service3} {
+				f := getDefinitionPathAWS("aws1.yml", s)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ernest("service", "apply", f)
+				}()
+			}
+			wg.Wait()
+
+			Convey("Then their events should never share a batch", func() {
+				So(tl.Wait("instance.create.aws-fake.done", 2), ShouldBeNil)
+
+				b2 := tl.BatchesFor("-" + service2 + "-")
+				b3 := tl.BatchesFor("-" + service3 + "-")
+				So(len(b2), ShouldBeGreaterThan, 0)
+				So(len(b3), ShouldBeGreaterThan, 0)
+				for _, id := range b2 {
+					So(b3, ShouldNotContain, id)
+				}
+			})
+
+			tl.Stop()
+		})
+	})
+}
+
+func TestCreatePhases(t *testing.T) {
+	cases := []struct {
+		definition string
+		phases     []string
+	}{
+		{"aws1.yml", []string{"firewall.create", "instance.create", "network.create"}},
+		{"aws12.yml", []string{"firewall.create", "instance.create", "nat.create", "network.create"}},
+		{"aws13.yml", []string{"elb.create", "firewall.create", "instance.create", "nat.create", "network.create", "s3.create"}},
+	}
+
+	Convey("Given an aws definition", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When I list the phases creating "+c.definition, func() {
+				def, err := ioutil.ReadFile(path.Join("definitions", c.definition))
+				So(err, ShouldBeNil)
+
+				Convey("Then there should be one for every kind of component it declares", func() {
+					So(createPhases(string(def), awsCreatePhases), ShouldResemble, c.phases)
+				})
+			})
+		}
+	})
+}
@@ -0,0 +1,139 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAWSDestroy(t *testing.T) {
+	var service = "awsd"
+	service = service + strconv.Itoa(rand.Intn(9999999))
+
+	basicSetup("aws")
+
+	Convey("Given I have a non existing aws definition", t, func() {
+		Convey("When I apply aws13.yml", func() {
+			f := getDefinitionPathAWS("aws13.yml", service)
+			tl, err := recordTimeline("*.create.aws-fake", "service.create.done")
+			So(err, ShouldBeNil)
+
+			_, err = ernest("service", "apply", f)
+			Convey("Then it should create the full service", func() {
+				if err != nil {
+					log.Println(err.Error())
+				}
+
+				So(tl.Wait("service.create.done", 1), ShouldBeNil)
+				So(len(tl.Matching("elb.create.*")), ShouldEqual, 1)
+				So(len(tl.Matching("s3.create.*")), ShouldEqual, 1)
+
+				Info("And should create networks and security groups before instances and elbs", " ", 6)
+				So(tl.Before("network.create.*", "nat.create.*"), ShouldBeNil)
+				So(tl.Before("network.create.*", "instance.create.*"), ShouldBeNil)
+				So(tl.Before("firewall.create.*", "instance.create.*"), ShouldBeNil)
+				So(tl.Before("instance.create.*", "elb.create.*"), ShouldBeNil)
+				So(tl.Follows(awsCreateOrder), ShouldBeEmpty)
+			})
+
+			tl.Stop()
+		})
+
+		Convey("When I destroy the current service", func() {
+			tl, err := recordTimeline("*.delete.aws-fake", "service.delete.done")
+			So(err, ShouldBeNil)
+
+			_, err = ernest("service", "destroy", "--force", service)
+			Convey("Then it should delete every resource", func() {
+				if err != nil {
+					log.Println(err.Error())
+				}
+
+				So(tl.Wait("service.delete.done", 1), ShouldBeNil)
+
+				Info("And should delete every resource once", " ", 6)
+				So(len(tl.Matching("elb.delete.*")), ShouldEqual, 1)
+				So(len(tl.Matching("s3.delete.*")), ShouldEqual, 1)
+				So(len(tl.Matching("instance.delete.*")), ShouldEqual, 1)
+				So(len(tl.Matching("firewall.delete.*")), ShouldEqual, 1)
+				So(len(tl.Matching("nat.delete.*")), ShouldEqual, 1)
+				So(len(tl.Matching("network.delete.*")), ShouldEqual, 2)
+
+				Info("And should delete elbs and instances before security groups and nat gateways", " ", 6)
+				So(tl.Before("elb.delete.*", "firewall.delete.*"), ShouldBeNil)
+				So(tl.Before("instance.delete.*", "firewall.delete.*"), ShouldBeNil)
+				So(tl.Before("elb.delete.*", "nat.delete.*"), ShouldBeNil)
+				So(tl.Before("instance.delete.*", "nat.delete.*"), ShouldBeNil)
+
+				Info("And should delete nat gateways before networks", " ", 6)
+				So(tl.Before("nat.delete.*", "network.delete.*"), ShouldBeNil)
+				So(tl.Follows(awsDeleteOrder), ShouldBeEmpty)
+
+				var subnets []string
+				for _, msg := range tl.Matching("*.delete.aws-fake") {
+					switch strings.Split(msg.Subject, ".")[0] {
+					case "elb":
+						e := awsELBEvent{}
+						json.Unmarshal(msg.Data, &e)
+						Info("And should call elb deleter connector with valid fields", " ", 6)
+						So(e.Name, ShouldEqual, "fakeaws-"+service+"-elb-1")
+						So(e.VpcID, ShouldEqual, "fakeaws")
+						So(e.DatacenterToken, ShouldEqualSecret, "fake")
+						So(e.DatacenterSecret, ShouldEqualSecret, default_aws_secret)
+					case "s3":
+						e := awsS3Event{}
+						json.Unmarshal(msg.Data, &e)
+						Info("And should call s3 deleter connector with valid fields", " ", 6)
+						So(e.Name, ShouldEqual, "bucket-1")
+					case "instance":
+						e := awsInstanceEvent{}
+						json.Unmarshal(msg.Data, &e)
+						Info("And should call instance deleter connector with valid fields", " ", 6)
+						So(e.InstanceName, ShouldEqual, "fakeaws-"+service+"-web-1")
+						So(e.InstanceAWSID, ShouldEqual, "foo")
+						So(e.NetworkAWSID, ShouldEqual, "foo")
+						So(e.DatacenterVpcID, ShouldEqual, "fakeaws")
+					case "firewall":
+						e := awsFirewallEvent{}
+						json.Unmarshal(msg.Data, &e)
+						Info("And should call firewall deleter connector with valid fields", " ", 6)
+						So(e.SecurityGroupName, ShouldEqual, "fakeaws-"+service+"-web-sg-1")
+						So(e.SecurityGroupAWSID, ShouldEqual, "foo")
+						So(e.DatacenterVPCID, ShouldEqual, "fakeaws")
+					case "nat":
+						e := awsNatEvent{}
+						json.Unmarshal(msg.Data, &e)
+						Info("And should call nat deleter connector with valid fields", " ", 6)
+						So(e.NatGatewayAWSID, ShouldEqual, "foo")
+						So(e.PublicNetworkAWSID, ShouldEqual, "foo")
+						So(len(e.RoutedNetworkAWSIDs), ShouldEqual, 1)
+						So(e.RoutedNetworkAWSIDs[0], ShouldEqual, "foo")
+					case "network":
+						e := awsNetworkEvent{}
+						json.Unmarshal(msg.Data, &e)
+						Info("And should call network deleter connector with valid fields", " ", 6)
+						So(e.NetworkAWSID, ShouldEqual, "foo")
+						So(e.DatacenterVpcID, ShouldEqual, "fakeaws")
+						subnets = append(subnets, e.NetworkSubnet)
+					}
+				}
+
+				Info("And should delete the public and the private networks", " ", 6)
+				sort.Strings(subnets)
+				So(subnets, ShouldResemble, []string{"10.1.0.0/24", "10.2.0.0/24"})
+			})
+
+			tl.Stop()
+		})
+	})
+}
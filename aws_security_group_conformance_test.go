@@ -0,0 +1,156 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// sgRule is a security group rule as written in a definition, ports are
+// kept as raw yaml scalars so quoted and numeric ports can be compared
+type sgRule struct {
+	IP       string
+	From     string
+	To       string
+	Protocol string
+	Expected awsFirewallRule
+}
+
+var sgIngress = []sgRule{
+	{"10.1.1.11/32", "'80'", "'80'", "tcp", awsFirewallRule{IP: "10.1.1.11/32", From: 80, To: 80, Protocol: "tcp"}},
+	{"10.1.1.11/32", "80", "80", "tcp", awsFirewallRule{IP: "10.1.1.11/32", From: 80, To: 80, Protocol: "tcp"}},
+	{"10.1.1.0/24", "53", "'53'", "udp", awsFirewallRule{IP: "10.1.1.0/24", From: 53, To: 53, Protocol: "udp"}},
+	{"10.1.1.0/24", "'0'", "'0'", "icmp", awsFirewallRule{IP: "10.1.1.0/24", From: 0, To: 0, Protocol: "icmp"}},
+	{"10.1.1.0/24", "'22'", "'22'", "any", awsFirewallRule{IP: "10.1.1.0/24", From: 22, To: 22, Protocol: "-1"}},
+	{"0.0.0.0/0", "'8000'", "'8010'", "tcp", awsFirewallRule{IP: "0.0.0.0/0", From: 8000, To: 8010, Protocol: "tcp"}},
+	{"10.1.1.12", "'443'", "'443'", "tcp", awsFirewallRule{IP: "10.1.1.12/32", From: 443, To: 443, Protocol: "tcp"}},
+	{"10.1.3.5/24", "'5000'", "'5100'", "udp", awsFirewallRule{IP: "10.1.3.0/24", From: 5000, To: 5100, Protocol: "udp"}},
+}
+
+var sgEgress = []sgRule{
+	{"0.0.0.0/0", "'0'", "'65535'", "any", awsFirewallRule{IP: "0.0.0.0/0", From: 0, To: 65535, Protocol: "-1"}},
+	{"10.2.0.0/24", "5432", "5432", "tcp", awsFirewallRule{IP: "10.2.0.0/24", From: 5432, To: 5432, Protocol: "tcp"}},
+	{"10.2.0.0/24", "'0'", "'0'", "icmp", awsFirewallRule{IP: "10.2.0.0/24", From: 0, To: 0, Protocol: "icmp"}},
+}
+
+var sgExtraIngress = sgRule{"10.1.4.0/24", "'9000'", "'9000'", "tcp", awsFirewallRule{IP: "10.1.4.0/24", From: 9000, To: 9000, Protocol: "tcp"}}
+var sgExtraEgress = sgRule{"10.1.4.0/24", "'9100'", "'9200'", "udp", awsFirewallRule{IP: "10.1.4.0/24", From: 9100, To: 9200, Protocol: "udp"}}
+
+func securityGroupDefinition(ingress, egress []sgRule) string {
+	def := "---\nname: my_service\ndatacenter: r3-dc2\nbootstrapping: none\nservice_ip: 172.16.186.44\nvpc_subnet: 1.1.1.1/24\n\n"
+	def += "networks:\n  - name: web\n    public: true\n    subnet: 10.1.0.0/24\n\n"
+	def += "security_groups:\n  - name: web-sg-1\n"
+	def += "    egress:\n" + securityGroupRules(egress)
+	def += "    ingress:\n" + securityGroupRules(ingress)
+
+	return def
+}
+
+func securityGroupRules(rules []sgRule) string {
+	var def string
+	for _, r := range rules {
+		def += "      - from_port: " + r.From + "\n"
+		def += "        ip: " + r.IP + "\n"
+		def += "        protocol: " + r.Protocol + "\n"
+		def += "        to_port: " + r.To + "\n"
+	}
+	return def
+}
+
+func expectedRules(rules []sgRule) []awsFirewallRule {
+	var expected []awsFirewallRule
+	for _, r := range rules {
+		expected = append(expected, r.Expected)
+	}
+	return expected
+}
+
+func TestAWSSecurityGroupConformance(t *testing.T) {
+	var service = "awssg"
+	service = service + strconv.Itoa(rand.Intn(9999999))
+
+	fiSub := make(chan *nats.Msg, 1)
+
+	ingress := append(append([]sgRule{}, sgIngress...), sgExtraIngress)
+	egress := append(append([]sgRule{}, sgEgress...), sgExtraEgress)
+
+	basicSetup("aws")
+
+	Convey("Given I have a non existing aws definition", t, func() {
+		Convey("When I apply a security group with every protocol and port format", func() {
+			f := writeDefinition(securityGroupDefinition(sgIngress, sgEgress), service, "fakeaws")
+			subFiC, _ := n.ChanSubscribe("firewall.create.aws-fake", fiSub)
+
+			_, err := ernest("service", "apply", f)
+			Convey("Then it should create the security group with the mapped rules", func() {
+				if err != nil {
+					log.Println(err.Error())
+				}
+
+				eventF := awsFirewallEvent{}
+				msg, err := waitMsg(fiSub)
+				So(err, ShouldBeNil)
+				json.Unmarshal(msg.Data, &eventF)
+
+				Info("And should call firewall creator connector with the exact ingress rules", " ", 6)
+				So(eventF.SecurityGroupName, ShouldEqual, "fakeaws-"+service+"-web-sg-1")
+				So(eventF.SecurityGroupRules.Ingress, ShouldResemble, expectedRules(sgIngress))
+
+				Info("And should call firewall creator connector with the exact egress rules", " ", 6)
+				So(eventF.SecurityGroupRules.Egress, ShouldResemble, expectedRules(sgEgress))
+			})
+
+			subFiC.Unsubscribe()
+		})
+
+		Convey("When I add an ingress and an egress rule", func() {
+			f := writeDefinition(securityGroupDefinition(ingress, egress), service, "fakeaws")
+			subFiU, _ := n.ChanSubscribe("firewall.update.aws-fake", fiSub)
+
+			_, err := ernest("service", "apply", f)
+			Convey("Then it should keep the existing rules in the same order", func() {
+				if err != nil {
+					log.Println(err.Error())
+				}
+
+				eventF := awsFirewallEvent{}
+				msg, err := waitMsg(fiSub)
+				So(err, ShouldBeNil)
+				json.Unmarshal(msg.Data, &eventF)
+
+				Info("And should call firewall updater connector with the new rules appended", " ", 6)
+				So(eventF.SecurityGroupRules.Ingress, ShouldResemble, expectedRules(ingress))
+				So(eventF.SecurityGroupRules.Egress, ShouldResemble, expectedRules(egress))
+			})
+
+			subFiU.Unsubscribe()
+		})
+
+		Convey("When I re-apply the same security group", func() {
+			f := writeDefinition(securityGroupDefinition(ingress, egress), service, "fakeaws")
+			subFiU, _ := n.ChanSubscribe("firewall.update.aws-fake", fiSub)
+
+			_, err := ernest("service", "apply", f)
+			Convey("Then it should not update the security group", func() {
+				if err != nil {
+					log.Println(err.Error())
+				}
+
+				_, err := waitMsgTime(fiSub, 2*time.Second)
+				So(err, ShouldNotBeNil)
+			})
+
+			subFiU.Unsubscribe()
+		})
+	})
+}
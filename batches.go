@@ -0,0 +1,180 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// replySubjects match every connector response, connectors answering a
+// request published on resource.action.provider on that subject followed by
+// done or error
+var replySubjects = []string{"*.*.*.done", "*.*.*.error"}
+
+// replySubject returns the subject a connector answers request on, status
+// being done or error
+func replySubject(request, status string) string {
+	return request + "." + status
+}
+
+// awsCreatePhases maps the top level lists of an aws definition to the
+// workflow phase creating their components
+var awsCreatePhases = map[string]string{
+	"networks":        "network.create",
+	"security_groups": "firewall.create",
+	"nat_gateways":    "nat.create",
+	"instances":       "instance.create",
+	"loadbalancers":   "elb.create",
+	"s3_buckets":      "s3.create",
+}
+
+// createPhases returns the phases creating a definition from scratch goes
+// through, one for every top level list holding components, sorted
+func createPhases(definition string, phases map[string]string) []string {
+	var found []string
+	section := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(definition))
+	for scanner.Scan() {
+		line := scanner.Text()
+		t := strings.TrimSpace(line)
+		if t == "" || strings.HasPrefix(t, "#") {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '-' {
+			section, _ = yamlField(t)
+			continue
+		}
+		if phase, ok := phases[section]; ok && strings.HasPrefix(t, "- ") {
+			found = append(found, phase)
+			section = ""
+		}
+	}
+
+	sort.Strings(found)
+	return found
+}
+
+// envelope holds the correlation fields the workflow adds to every
+// connector message
+type envelope struct {
+	UUID    string `json:"_uuid"`
+	BatchID string `json:"_batch_id"`
+	Name    string `json:"name"`
+}
+
+func (e timelineEntry) envelope() envelope {
+	env := envelope{}
+	json.Unmarshal(e.Data, &env)
+	return env
+}
+
+// phase returns the workflow phase of a message, as resource.action
+func (e timelineEntry) phase() string {
+	parts := strings.Split(e.Subject, ".")
+	if len(parts) < 2 {
+		return e.Subject
+	}
+	return parts[0] + "." + parts[1]
+}
+
+func (e timelineEntry) isResponse() bool {
+	return strings.HasSuffix(e.Subject, ".done") || strings.HasSuffix(e.Subject, ".error")
+}
+
+// Batches groups the recorded messages by their batch id, messages with no
+// batch id are left out
+func (t *timeline) Batches() map[string][]timelineEntry {
+	batches := make(map[string][]timelineEntry)
+	for _, e := range t.Entries() {
+		if id := e.envelope().BatchID; id != "" {
+			batches[id] = append(batches[id], e)
+		}
+	}
+	return batches
+}
+
+// BatchesFor returns the batch ids of the messages whose resource name
+// contains filter
+func (t *timeline) BatchesFor(filter string) []string {
+	ids := make(map[string]bool)
+	for _, e := range t.Entries() {
+		env := e.envelope()
+		if env.BatchID != "" && strings.Contains(env.Name, filter) {
+			ids[env.BatchID] = true
+		}
+	}
+
+	var batches []string
+	for id := range ids {
+		batches = append(batches, id)
+	}
+	sort.Strings(batches)
+	return batches
+}
+
+// CheckBatches verifies each workflow phase runs in its own batch and each
+// request is correlated with its response by uuid
+func (t *timeline) CheckBatches() []string {
+	var problems []string
+
+	phases := make(map[string]string)
+	owners := make(map[string]string)
+	requests := make(map[string]envelope)
+	responses := make(map[string]envelope)
+
+	for _, e := range t.Entries() {
+		env := e.envelope()
+
+		if e.isResponse() {
+			responses[env.UUID] = env
+			continue
+		}
+
+		if env.UUID == "" || env.BatchID == "" {
+			problems = append(problems, fmt.Sprintf("%s has no uuid or batch id", e.Subject))
+			continue
+		}
+
+		if _, ok := requests[env.UUID]; ok {
+			problems = append(problems, fmt.Sprintf("%s reuses uuid %s", e.Subject, env.UUID))
+		}
+		requests[env.UUID] = env
+
+		phase := e.phase()
+		if batch, ok := phases[phase]; ok && batch != env.BatchID {
+			problems = append(problems, fmt.Sprintf("%s is split across batches %s and %s", phase, batch, env.BatchID))
+		}
+		phases[phase] = env.BatchID
+
+		if owner, ok := owners[env.BatchID]; ok && owner != phase {
+			problems = append(problems, fmt.Sprintf("batch %s is shared by %s and %s", env.BatchID, owner, phase))
+		}
+		owners[env.BatchID] = phase
+	}
+
+	var uuids []string
+	for uuid := range requests {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	for _, uuid := range uuids {
+		res, ok := responses[uuid]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s has no response", uuid))
+			continue
+		}
+		if res.BatchID != requests[uuid].BatchID {
+			problems = append(problems, fmt.Sprintf("%s response moved from batch %s to %s", uuid, requests[uuid].BatchID, res.BatchID))
+		}
+	}
+
+	return problems
+}
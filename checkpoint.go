@@ -0,0 +1,155 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// checkpoint is the state a scenario left its service in after its last
+// run step
+type checkpoint struct {
+	Scenario string    `json:"scenario"`
+	Service  string    `json:"service"`
+	Step     int       `json:"step"`
+	Name     string    `json:"name"`
+	Build    string    `json:"build"`
+	Status   string    `json:"status"`
+	Errors   []string  `json:"errors,omitempty"`
+	Saved    time.Time `json:"saved"`
+}
+
+// checkpointDir returns the directory the checkpoints of a scenario are
+// kept on, one per service, under UAT_CHECKPOINT_DIR or the temporary
+// directory
+func checkpointDir(scenario string) string {
+	dir := os.Getenv("UAT_CHECKPOINT_DIR")
+	if dir == "" {
+		dir = path.Join(os.TempDir(), "uat-checkpoints")
+	}
+	return path.Join(dir, scenario)
+}
+
+// newCheckpoint returns the state of service after the step i of a
+// scenario, along with the latest build stored for it
+func newCheckpoint(s scenario, i int, service string, errs []string) checkpoint {
+	c := checkpoint{
+		Scenario: s.Name,
+		Service:  service,
+		Step:     i,
+		Name:     s.Steps[i].Name(),
+		Errors:   errs,
+		Saved:    time.Now(),
+	}
+	if svc, err := getService(service); err == nil {
+		c.Build, c.Status = svc.ID, svc.Status
+	}
+	return c
+}
+
+// save writes a checkpoint over the previous one of its scenario and
+// service
+func (c checkpoint) save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := checkpointDir(c.Scenario)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(dir, c.Service+".json"), data, 0644)
+}
+
+// loadCheckpoint reads the checkpoint of a scenario run on service, or the
+// latest saved one of the scenario when no service is given
+func loadCheckpoint(scenario, service string) (checkpoint, error) {
+	c := checkpoint{}
+	if service != "" {
+		data, err := ioutil.ReadFile(path.Join(checkpointDir(scenario), service+".json"))
+		if err != nil {
+			return c, err
+		}
+		err = json.Unmarshal(data, &c)
+		return c, err
+	}
+
+	files, err := filepath.Glob(path.Join(checkpointDir(scenario), "*.json"))
+	if err != nil {
+		return c, err
+	}
+	found := false
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return c, err
+		}
+		saved := checkpoint{}
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return c, fmt.Errorf("%s: %s", f, err.Error())
+		}
+		if !found || saved.Saved.After(c.Saved) {
+			c, found = saved, true
+		}
+	}
+	if !found {
+		return c, errors.New("no checkpoint saved for " + scenario)
+	}
+	return c, nil
+}
+
+// reusable reports if the service of a checkpoint still holds the state
+// step i starts from, svc being its latest stored build: the checkpoint was
+// saved after the step before with no unmet expectation, and no build was
+// applied on the service since
+func (c checkpoint) reusable(i int, svc storedService) bool {
+	if c.Step != i-1 || len(c.Errors) > 0 || c.Build == "" {
+		return false
+	}
+	return svc.ID == c.Build && svc.Status == c.Status
+}
+
+// resumeStep returns the index of the step of s named from
+func (s scenario) resumeStep(from string) (int, error) {
+	for i, step := range s.Steps {
+		if step.Apply == from || step.Name() == from {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%s has no step %s", s.Name, from)
+}
+
+// resume returns how a run of s resuming from the step named from starts.
+// The checkpointed service, the one of service or the latest one when no
+// service is given, is reused when it still holds the state the step starts
+// from. Otherwise the step runs on service, empty for a new one, once its
+// prerequisites are applied
+func (s scenario) resume(from, service string) (runOptions, string, error) {
+	i, err := s.resumeStep(from)
+	if err != nil {
+		return runOptions{}, service, err
+	}
+
+	basicSetup(s.Provider)
+	if c, err := loadCheckpoint(s.Name, service); err == nil {
+		if svc, err := getService(c.Service); err == nil && c.reusable(i, svc) {
+			return runOptions{Start: i, Ready: true}, c.Service, nil
+		}
+	}
+	return runOptions{Start: i}, service, nil
+}
+
+// hasStep reports if s holds a step named from
+func (s scenario) hasStep(from string) bool {
+	_, err := s.resumeStep(from)
+	return err == nil
+}
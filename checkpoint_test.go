@@ -0,0 +1,175 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// destroyedScenario applies, destroys and applies again a service
+var destroyedScenario = scenario{Name: "vse", Steps: []scenarioStep{
+	{Apply: "vse1.yml"},
+	{Command: []string{"service", "info", "{service}"}},
+	{Apply: "vse2.yml"},
+	{Destroy: true},
+	{Command: []string{"service", "list"}},
+	{Apply: "vse1.yml"},
+	{Apply: "vse3.yml"},
+}}
+
+func TestPrerequisites(t *testing.T) {
+	cases := []struct {
+		step          int
+		prerequisites []string
+	}{
+		{0, nil},
+		{1, []string{"service apply vse1.yml"}},
+		{2, []string{"service apply vse1.yml"}},
+		{3, []string{"service apply vse2.yml"}},
+		{4, []string{"service apply vse2.yml", "service destroy"}},
+		{5, []string{"service apply vse2.yml", "service destroy"}},
+		{6, []string{"service apply vse1.yml"}},
+	}
+
+	Convey("Given a scenario destroying its service half way", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When I start from "+destroyedScenario.Steps[c.step].Name(), func() {
+				var names []string
+				for _, step := range destroyedScenario.prerequisites(c.step) {
+					names = append(names, step.Name())
+				}
+
+				Convey("Then the steps rebuilding its state should be replayed first", func() {
+					So(names, ShouldResemble, c.prerequisites)
+				})
+			})
+		}
+	})
+}
+
+func TestCheckpointReusable(t *testing.T) {
+	saved := checkpoint{Scenario: "vse", Service: "vse42", Step: 7, Build: "b8", Status: "done"}
+	failed := saved
+	failed.Errors = []string{"expected 1 nat.update.vcloud-fake messages but got 0"}
+	unsaved := saved
+	unsaved.Build = ""
+
+	cases := []struct {
+		description string
+		c           checkpoint
+		step        int
+		latest      storedService
+		reusable    bool
+	}{
+		{"the step after the checkpoint", saved, 8, storedService{ID: "b8", Status: "done"}, true},
+		{"a step further on", saved, 9, storedService{ID: "b8", Status: "done"}, false},
+		{"the step after a newer build", saved, 8, storedService{ID: "b9", Status: "done"}, false},
+		{"the step after a build changing status", saved, 8, storedService{ID: "b8", Status: "errored"}, false},
+		{"the step after a failed step", failed, 8, storedService{ID: "b8", Status: "done"}, false},
+		{"the step after no stored build", unsaved, 8, storedService{}, false},
+	}
+
+	Convey("Given the checkpoint of a scenario", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When I resume from "+c.description, func() {
+				Convey("Then its service should only be reused when it holds the state the step starts from", func() {
+					So(c.c.reusable(c.step, c.latest), ShouldEqual, c.reusable)
+				})
+			})
+		}
+	})
+}
+
+func TestResumeStep(t *testing.T) {
+	cases := []struct {
+		from string
+		step int
+		err  string
+	}{
+		{"vse2.yml", 2, ""},
+		{"service apply vse3.yml", 6, ""},
+		{"service list", 4, ""},
+		{"vse9.yml", 0, "vse has no step vse9.yml"},
+	}
+
+	Convey("Given a scenario", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When I resume from "+c.from, func() {
+				i, err := destroyedScenario.resumeStep(c.from)
+
+				Convey("Then the run should start on its first step with that name", func() {
+					if c.err != "" {
+						So(err, ShouldNotBeNil)
+						So(err.Error(), ShouldEqual, c.err)
+						So(destroyedScenario.hasStep(c.from), ShouldBeFalse)
+						return
+					}
+					So(err, ShouldBeNil)
+					So(i, ShouldEqual, c.step)
+					So(destroyedScenario.hasStep(c.from), ShouldBeTrue)
+				})
+			})
+		}
+	})
+}
+
+func TestLoadCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uat-checkpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("UAT_CHECKPOINT_DIR", dir)
+	defer os.Unsetenv("UAT_CHECKPOINT_DIR")
+
+	now := time.Now()
+	checkpoints := []checkpoint{
+		{Scenario: "vse", Service: "vse1", Step: 8, Build: "a9", Saved: now.Add(-time.Hour)},
+		{Scenario: "vse", Service: "vse2", Step: 3, Build: "b4", Saved: now},
+		{Scenario: "vse-salt", Service: "vsesalt1", Step: 2, Build: "c3", Saved: now.Add(time.Hour)},
+	}
+
+	Convey("Given the checkpoints of two runs of a scenario", t, func() {
+		for _, c := range checkpoints {
+			So(c.save(), ShouldBeNil)
+		}
+
+		Convey("When I load the checkpoint of a service", func() {
+			c, err := loadCheckpoint("vse", "vse1")
+
+			Convey("Then I should get the one of that run", func() {
+				So(err, ShouldBeNil)
+				So(c.Service, ShouldEqual, "vse1")
+				So(c.Build, ShouldEqual, "a9")
+			})
+		})
+
+		Convey("When I load the checkpoint of the scenario", func() {
+			c, err := loadCheckpoint("vse", "")
+
+			Convey("Then I should get the latest one saved by any of its runs", func() {
+				So(err, ShouldBeNil)
+				So(c.Service, ShouldEqual, "vse2")
+				So(c.Step, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When I load the checkpoint of a scenario never run", func() {
+			_, err := loadCheckpoint("aws", "")
+
+			Convey("Then I should get an error", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
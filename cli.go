@@ -7,7 +7,6 @@ package main
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -27,15 +26,28 @@ var salt struct {
 	Password string `json:"password"`
 }
 
+// the default passwords are values no message holds by chance, so the leak
+// audit and the redaction can look for them
 var admin_usr = "ci_admin"
-var admin_pwd = "pwd"
+var admin_pwd = "uat-adm-Pw-7f3c"
 var default_usr = "usr"
-var default_pwd = "pwd"
+var default_pwd = "uat-usr-Pw-4b1e"
 var default_org = "org"
+var default_group = "test"
+var default_aws_secret = "uat-aws-Sk-9d2a"
 var ernest_instance = "https://ernest.local/"
+
+const cliDriver = "cli"
+const httpDriver = "http"
+
+// driver is the way scenarios talk to ernest, set with UAT_DRIVER
+var driver = os.Getenv("UAT_DRIVER")
 var endSub = make(chan *nats.Msg, 1)
 
 var setup = false
+
+// definitions counts the definitions written, to give each one its own file
+var definitions = 0
 var n *nats.Conn
 
 func wait(ch chan bool) error {
@@ -52,10 +64,14 @@ func waitTime(ch chan bool, timeout time.Duration) error {
 }
 
 func waitMsg(ch chan *nats.Msg) (*nats.Msg, error) {
+	return waitMsgTime(ch, time.Millisecond*10000)
+}
+
+func waitMsgTime(ch chan *nats.Msg, timeout time.Duration) (*nats.Msg, error) {
 	select {
 	case msg := <-ch:
 		return msg, nil
-	case <-time.After(time.Millisecond * 10000):
+	case <-time.After(timeout):
 	}
 	return nil, errors.New("timeout")
 }
@@ -67,8 +83,6 @@ func waitToDone() {
 }
 
 func getDefinitionPath(def string, service string) string {
-	finalPath := "/tmp/currentTest.yml"
-
 	_, filename, _, _ := runtime.Caller(1)
 	filePath := path.Join(path.Dir(filename), "definitions", def)
 
@@ -77,30 +91,22 @@ func getDefinitionPath(def string, service string) string {
 		log.Fatalln(err)
 	}
 
-	lines := strings.Split(string(input), "\n")
-	var finalLines []string
+	return writeDefinition(string(input), service, "fake")
+}
 
-	for _, line := range lines {
-		if strings.Contains(line, "name: my_service") {
-			finalLines = append(finalLines, "name: "+service)
-		} else if strings.Contains(line, "datacenter: r3-dc2") {
-			finalLines = append(finalLines, "datacenter: fake")
-		} else {
-			finalLines = append(finalLines, line)
-		}
-	}
-	output := strings.Join(finalLines, "\n")
-	err = ioutil.WriteFile(finalPath, []byte(output), 0644)
+func getDefinitionPathAWS(def string, service string) string {
+	_, filename, _, _ := runtime.Caller(1)
+	filePath := path.Join(path.Dir(filename), "definitions", def)
+
+	input, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	return finalPath
+	return writeDefinition(string(input), service, "fakeaws")
 }
 
-func getDefinitionPathAWS(def string, service string) string {
-	finalPath := "/tmp/currentTest.yml"
-
+func getDefinitionPathOn(def string, service string, datacenter string) string {
 	_, filename, _, _ := runtime.Caller(1)
 	filePath := path.Join(path.Dir(filename), "definitions", def)
 
@@ -109,20 +115,29 @@ func getDefinitionPathAWS(def string, service string) string {
 		log.Fatalln(err)
 	}
 
-	lines := strings.Split(string(input), "\n")
+	return writeDefinition(string(input), service, datacenter)
+}
+
+// writeDefinition stores a definition with its service name and datacenter
+// replaced on a file of its own, and returns its path
+func writeDefinition(input string, service string, datacenter string) string {
+	definitions++
+	finalPath := "/tmp/" + service + "-" + strconv.Itoa(definitions) + ".yml"
+
+	lines := strings.Split(input, "\n")
 	var finalLines []string
 
 	for _, line := range lines {
 		if strings.Contains(line, "name: my_service") {
 			finalLines = append(finalLines, "name: "+service)
 		} else if strings.Contains(line, "datacenter: r3-dc2") {
-			finalLines = append(finalLines, "datacenter: fakeaws")
+			finalLines = append(finalLines, "datacenter: "+datacenter)
 		} else {
 			finalLines = append(finalLines, line)
 		}
 	}
 	output := strings.Join(finalLines, "\n")
-	err = ioutil.WriteFile(finalPath, []byte(output), 0644)
+	err := ioutil.WriteFile(finalPath, []byte(output), 0644)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -138,12 +153,27 @@ func basicSetup(provider string) {
 			panic(err)
 		}
 
-		msg, err := n.Request("config.get.salt", []byte("{}"), time.Second)
+		msg, err := request("config.get.salt", []byte("{}"), time.Second)
 		if err != nil {
 			panic("Salt config not accessible")
 		}
 		json.Unmarshal(msg.Data, &salt)
 
+		registerSecret("salt password", salt.Password)
+		registerDefaultSecrets()
+
+		if executionsByHarness {
+			if err := answerExecutions(); err != nil {
+				panic(err)
+			}
+		}
+
+		if dir := os.Getenv("UAT_RECORD_DIR"); dir != "" {
+			if err := recordRun(dir); err != nil {
+				panic(err)
+			}
+		}
+
 		if os.Getenv("CURRENT_INSTANCE") != "" {
 			ernest_instance = os.Getenv("CURRENT_INSTANCE")
 		}
@@ -152,15 +182,15 @@ func basicSetup(provider string) {
 
 		// Create user
 		ernest("user", "create", default_usr, default_pwd)
-		ernest("group", "create", "test")
-		ernest("group", "add-user", default_usr, "test")
+		ernest("group", "create", default_group)
+		ernest("group", "add-user", default_usr, default_group)
 
 		// Login as this user
 		login()
 
 		// Create a datacenter
 		ernest("datacenter", "create", "vcloud", "fake", "--vcloud-url", "https://myvdc.me.com", "--fake", "--user", default_usr, "--password", default_pwd, "--org", default_org, "--vse-url", "http://localhost", "--public-network", "NETWORK")
-		ernest("datacenter", "create", "aws", "fakeaws", "--region", "fake", "--token", "fake", "--secret", "secret", "--fake")
+		ernest("datacenter", "create", "aws", "fakeaws", "--region", "fake", "--token", "fake", "--secret", default_aws_secret, "--fake")
 
 		setup = true
 	} else {
@@ -182,6 +212,18 @@ func deleteConfig() {
 }
 
 func ernest(cmdArgs ...string) (string, error) {
+	return ernestAs("", cmdArgs...)
+}
+
+// ernestAs runs ernest-cli with a home directory of its own for usr, so
+// several users can be logged in at the same time
+func ernestAs(usr string, cmdArgs ...string) (string, error) {
+	return ernestVia(driver, usr, cmdArgs...)
+}
+
+// ernestVia runs a cli command for usr on the given driver, the http one
+// calling the api-gateway directly instead of ernest-cli
+func ernestVia(d string, usr string, cmdArgs ...string) (string, error) {
 	if cmdArgs[1] == "apply" {
 		if delay := os.Getenv("ERNEST_APPLY_DELAY"); delay != "" {
 			if t, err := strconv.Atoi(delay); err == nil {
@@ -190,7 +232,16 @@ func ernest(cmdArgs ...string) (string, error) {
 			}
 		}
 	}
+	if d == httpDriver {
+		return apiAs(usr, cmdArgs...)
+	}
+
 	cmd := exec.Command("ernest-cli", cmdArgs...)
+	if usr != "" {
+		home := path.Join(os.TempDir(), "ernest-"+usr)
+		os.MkdirAll(home, 0755)
+		cmd.Env = append(os.Environ(), "HOME="+home)
+	}
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		println(err.Error())
@@ -198,6 +249,12 @@ func ernest(cmdArgs ...string) (string, error) {
 	return string(output), nil
 }
 
+// loginAs logs usr in on its own home directory
+func loginAs(usr, pwd string) {
+	ernestAs(usr, "target", ernest_instance)
+	ernestAs(usr, "login", "--user", usr, "--password", pwd)
+}
+
 func Info(str, pad string, l int) {
 	for i := 0; i < l; i++ {
 		str = pad + str
@@ -205,19 +262,3 @@ func Info(str, pad string, l int) {
 
 	print("\n" + str + " ")
 }
-
-func CheckOutput(ol, cl []string) bool {
-	for i, v := range ol {
-		if i < len(cl) {
-			if cl[i] != "" {
-				if cl[i] != v {
-					fmt.Printf("\nOutput line %d expected to be: \n%s\nbut found\n%s", i, cl[i], v)
-					return false
-				}
-			}
-		}
-	}
-	return true
-}
-
-func main() {}
@@ -0,0 +1,119 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var concurrentApplies = 3
+
+// concurrentGrace is how long a step keeps recording once its service is
+// created, so the instances of a workflow started late are still counted
+var concurrentGrace = 3 * time.Second
+
+// applyConcurrently applies the same definition once per user, all at once,
+// and returns the cli output of each apply
+func applyConcurrently(f string, users []string) []string {
+	outputs := make([]string, len(users))
+
+	var wg sync.WaitGroup
+	for i, usr := range users {
+		wg.Add(1)
+		go func(i int, usr string) {
+			defer wg.Done()
+			outputs[i], _ = ernestAs(usr, "service", "apply", f)
+		}(i, usr)
+	}
+	wg.Wait()
+
+	return outputs
+}
+
+// splitOutputs separates the outputs of the rejected applies from the rest
+func splitOutputs(outputs []string) (accepted []string, rejected []string) {
+	for _, o := range outputs {
+		if strings.Contains(o, "in progress") {
+			rejected = append(rejected, o)
+		} else {
+			accepted = append(accepted, o)
+		}
+	}
+	return accepted, rejected
+}
+
+func TestConcurrentApply(t *testing.T) {
+	var service = "lock"
+
+	service = service + strconv.Itoa(rand.Intn(9999999))
+	service2 := service + "II"
+	member := newMember(service)
+
+	basicSetup("vcloud")
+	member.join()
+	login()
+
+	Convey("Given I have a configured ernest instance", t, func() {
+		Convey("When the same user applies inst1.yml several times at once", func() {
+			tl, err := recordTimeline("service.create", "service.create.done", "instance.create.vcloud-fake")
+			So(err, ShouldBeNil)
+			f := getDefinitionPath("inst1.yml", service)
+
+			var users []string
+			for i := 0; i < concurrentApplies; i++ {
+				users = append(users, default_usr)
+			}
+			outputs := applyConcurrently(f, users)
+
+			Convey("Then only one apply should start a workflow", func() {
+				accepted, rejected := splitOutputs(outputs)
+				So(len(accepted), ShouldEqual, 1)
+				So(len(rejected), ShouldEqual, concurrentApplies-1)
+
+				Info("And every other apply should get the same in progress error", " ", 8)
+				for _, o := range rejected {
+					So(o, ShouldEqual, rejected[0])
+				}
+
+				Info("And only one instance should be created", " ", 8)
+				So(tl.Wait("service.create.done", 1), ShouldBeNil)
+				time.Sleep(concurrentGrace)
+				So(len(tl.Matching("service.create")), ShouldEqual, 1)
+				So(len(tl.Matching("instance.create.vcloud-fake")), ShouldEqual, 1)
+			})
+
+			tl.Stop()
+		})
+
+		Convey("When two users of the same group apply inst1.yml at once", func() {
+			tl, err := recordTimeline("service.create", "service.create.done", "instance.create.vcloud-fake")
+			So(err, ShouldBeNil)
+			f := getDefinitionPath("inst1.yml", service2)
+
+			outputs := applyConcurrently(f, []string{default_usr, member.User})
+
+			Convey("Then only one apply should start a workflow", func() {
+				accepted, rejected := splitOutputs(outputs)
+				So(len(accepted), ShouldEqual, 1)
+				So(len(rejected), ShouldEqual, 1)
+
+				Info("And only one instance should be created", " ", 8)
+				So(tl.Wait("service.create.done", 1), ShouldBeNil)
+				time.Sleep(concurrentGrace)
+				So(len(tl.Matching("service.create")), ShouldEqual, 1)
+				So(len(tl.Matching("instance.create.vcloud-fake")), ShouldEqual, 1)
+			})
+
+			tl.Stop()
+		})
+	})
+}
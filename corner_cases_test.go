@@ -8,7 +8,6 @@ import (
 	"encoding/json"
 	"log"
 	"math/rand"
-	"os"
 	"strconv"
 	"strings"
 	"testing"
@@ -46,17 +45,12 @@ func TestPatchService(t *testing.T) {
 				o, err := ernest("service", "apply", f)
 				if err != nil {
 					log.Println(err.Error())
-				} else {
-					lines := strings.Split(o, "\n")
-					checkLines := make([]string, 21)
-
-					checkLines[0] = "Environment creation requested"
-
-					vo := CheckOutput(lines, checkLines)
-					if os.Getenv("CHECK_OUTPUT") != "" {
-						So(vo, ShouldEqual, true)
-					}
 				}
+				So(matchGolden("inst1_apply", o, map[string]string{"service": service}), ShouldBeEmpty)
+
+				Info("And service info should show the created instance", " ", 8)
+				o, _ = ernest("service", "info", service)
+				So(matchGolden("inst1_info", o, map[string]string{"service": service}), ShouldBeEmpty)
 
 				msg, err := waitMsg(inCreateServiceSub)
 				So(err, ShouldBeNil)
@@ -69,7 +63,7 @@ func TestPatchService(t *testing.T) {
 
 				Info("And I should receive a valid instance.create.vcloud-fake", " ", 8)
 				So(event.DatacenterName, ShouldEqual, "fake")
-				So(event.DatacenterPassword, ShouldEqual, default_pwd)
+				So(event.DatacenterPassword, ShouldEqualSecret, default_pwd)
 				So(event.DatacenterRegion, ShouldEqual, "$(datacenters.items.0.region)")
 				So(event.DatacenterType, ShouldEqual, "vcloud-fake")
 				So(event.DatacenterUsername, ShouldEqual, default_usr+"@"+default_org)
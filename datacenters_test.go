@@ -0,0 +1,208 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/nats-io/nats"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// invalidDatacenter is a datacenter create command the cli must reject with
+// the validation error err, usage text listing every flag
+type invalidDatacenter struct {
+	description string
+	err         string
+	args        []string
+}
+
+func vcloudDatacenterArgs(name string, skip string, override ...string) []string {
+	flags := [][]string{
+		{"--vcloud-url", "https://myvdc.me.com"},
+		{"--vse-url", "http://localhost"},
+		{"--public-network", "NETWORK"},
+		{"--user", default_usr},
+		{"--password", default_pwd},
+		{"--org", default_org},
+	}
+	return datacenterArgs("vcloud", name, flags, skip, override...)
+}
+
+func awsDatacenterArgs(name string, skip string, override ...string) []string {
+	flags := [][]string{
+		{"--region", "fake"},
+		{"--token", "fake"},
+		{"--secret", default_aws_secret},
+	}
+	return datacenterArgs("aws", name, flags, skip, override...)
+}
+
+// datacenterArgs builds a datacenter create command leaving out the skip
+// flag, override being pairs of flags and values replacing the defaults
+func datacenterArgs(provider, name string, flags [][]string, skip string, override ...string) []string {
+	values := make(map[string]string)
+	for i := 0; i+1 < len(override); i += 2 {
+		values[override[i]] = override[i+1]
+	}
+
+	args := []string{"datacenter", "create", provider, name}
+	for _, f := range flags {
+		if f[0] == skip {
+			continue
+		}
+		if v, ok := values[f[0]]; ok {
+			f[1] = v
+		}
+		args = append(args, f[0], f[1])
+	}
+	return append(args, "--fake")
+}
+
+func TestDatacenters(t *testing.T) {
+	var suffix = strconv.Itoa(rand.Intn(9999999))
+
+	vcloudDC := "dcv" + suffix
+	awsDC := "dca" + suffix
+	emptyDC := "dce" + suffix
+	service := "dcs" + suffix
+	serviceAWS := "dcsa" + suffix
+
+	inCreateSub := make(chan *nats.Msg, 1)
+
+	invalid := []invalidDatacenter{
+		{"no vcloud url", "VCloud URL is empty", vcloudDatacenterArgs("bad1"+suffix, "--vcloud-url")},
+		{"an invalid vcloud url", "VCloud URL is not valid", vcloudDatacenterArgs("bad2"+suffix, "", "--vcloud-url", "not a url")},
+		{"an invalid vse url", "VSE URL is not valid", vcloudDatacenterArgs("bad3"+suffix, "", "--vse-url", "not a url")},
+		{"no public network", "Public Network is empty", vcloudDatacenterArgs("bad4"+suffix, "--public-network")},
+		{"no region", "Region is empty", awsDatacenterArgs("bad5"+suffix, "--region")},
+		{"no token", "Token is empty", awsDatacenterArgs("bad6"+suffix, "--token")},
+		{"no secret", "Secret is empty", awsDatacenterArgs("bad7"+suffix, "--secret")},
+	}
+
+	basicSetup("vcloud")
+
+	Convey("Given I have a configured ernest instance", t, func() {
+		for _, c := range invalid {
+			c := c
+			Convey("When I create a datacenter with "+c.description, func() {
+				o, _ := ernest(c.args...)
+
+				Convey("Then it should be rejected", func() {
+					Info("And the cli should print "+c.err, " ", 8)
+					So(o, ShouldContainSubstring, c.err)
+
+					d, _ := getDatacenter(c.args[3])
+					So(d.ID, ShouldEqual, 0)
+				})
+			})
+		}
+
+		Convey("When I create valid vcloud and aws datacenters", func() {
+			ernest(vcloudDatacenterArgs(vcloudDC, "")...)
+			ernest(awsDatacenterArgs(awsDC, "")...)
+
+			Convey("Then they should be stored", func() {
+				v, err := getDatacenter(vcloudDC)
+				So(err, ShouldBeNil)
+				So(v.Type, ShouldEqual, "vcloud-fake")
+				So(v.VCloudURL, ShouldEqual, "https://myvdc.me.com")
+				So(v.ExternalNetwork, ShouldEqual, "NETWORK")
+
+				a, err := getDatacenter(awsDC)
+				So(err, ShouldBeNil)
+				So(a.Type, ShouldEqual, "aws-fake")
+				So(a.Region, ShouldEqual, "fake")
+			})
+		})
+
+		Convey("When I create a datacenter with an existing name", func() {
+			previous, _ := getDatacenter(vcloudDC)
+			o, _ := ernest(vcloudDatacenterArgs(vcloudDC, "", "--org", "other")...)
+
+			Convey("Then it should be rejected", func() {
+				So(o, ShouldContainSubstring, "already exists")
+
+				d, err := getDatacenter(vcloudDC)
+				So(err, ShouldBeNil)
+				So(d.ID, ShouldEqual, previous.ID)
+			})
+		})
+
+		Convey("When I update the credentials of a vcloud datacenter with a service", func() {
+			ernest("service", "apply", getDefinitionPathOn("inst1.yml", service, vcloudDC))
+			ernest("datacenter", "update", "vcloud", vcloudDC, "--user", "newusr", "--password", "newpwd")
+
+			sub, _ := n.ChanSubscribe("instance.create.vcloud-fake", inCreateSub)
+			_, err := ernest("service", "apply", getDefinitionPathOn("inst2.yml", service, vcloudDC))
+
+			Convey("Then the next apply should use the new credentials", func() {
+				if err != nil {
+					log.Println(err.Error())
+				}
+
+				event := instanceEvent{}
+				msg, err := waitMsg(inCreateSub)
+				So(err, ShouldBeNil)
+				json.Unmarshal(msg.Data, &event)
+
+				So(event.DatacenterName, ShouldEqual, vcloudDC)
+				So(event.DatacenterUsername, ShouldEqual, "newusr@"+default_org)
+				So(event.DatacenterPassword, ShouldEqualSecret, "newpwd")
+			})
+
+			sub.Unsubscribe()
+		})
+
+		Convey("When I update the credentials of an aws datacenter with a service", func() {
+			ernest("service", "apply", getDefinitionPathOn("aws1.yml", serviceAWS, awsDC))
+			ernest("datacenter", "update", "aws", awsDC, "--token", "newtoken", "--secret", "newsecret")
+
+			sub, _ := n.ChanSubscribe("instance.create.aws-fake", inCreateSub)
+			_, err := ernest("service", "apply", getDefinitionPathOn("aws2.yml", serviceAWS, awsDC))
+
+			Convey("Then the next apply should use the new credentials", func() {
+				if err != nil {
+					log.Println(err.Error())
+				}
+
+				event := awsInstanceEvent{}
+				msg, err := waitMsg(inCreateSub)
+				So(err, ShouldBeNil)
+				json.Unmarshal(msg.Data, &event)
+
+				So(event.DatacenterAccessToken, ShouldEqualSecret, "newtoken")
+				So(event.DatacenterAccessKey, ShouldEqualSecret, "newsecret")
+			})
+
+			sub.Unsubscribe()
+		})
+
+		Convey("When I delete a datacenter with services", func() {
+			previous, _ := getDatacenter(vcloudDC)
+			ernest("datacenter", "delete", vcloudDC)
+
+			Convey("Then it should be kept", func() {
+				d, err := getDatacenter(vcloudDC)
+				So(err, ShouldBeNil)
+				So(d.ID, ShouldEqual, previous.ID)
+			})
+		})
+
+		Convey("When I delete a datacenter with no services", func() {
+			ernest(awsDatacenterArgs(emptyDC, "")...)
+			ernest("datacenter", "delete", emptyDC)
+
+			Convey("Then it should be removed", func() {
+				d, _ := getDatacenter(emptyDC)
+				So(d.ID, ShouldEqual, 0)
+			})
+		})
+	})
+}
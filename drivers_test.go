@@ -0,0 +1,119 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDriverParity(t *testing.T) {
+	var suffix = strconv.Itoa(rand.Intn(9999999))
+
+	drivers := []string{cliDriver, httpDriver}
+	events := make(map[string]instanceEvent)
+	inCreateSub := make(chan *nats.Msg, 1)
+
+	basicSetup("vcloud")
+	ernestVia(httpDriver, "", "target", ernest_instance)
+	ernestVia(httpDriver, "", "login", "--user", default_usr, "--password", default_pwd)
+
+	Convey("Given I can reach ernest through the cli and the api-gateway", t, func() {
+		for _, d := range drivers {
+			d := d
+			service := "parity" + d + suffix
+
+			Convey("When I apply inst1.yml with the "+d+" driver", func() {
+				sub, _ := n.ChanSubscribe("instance.create.vcloud-fake", inCreateSub)
+				ernestVia(d, "", "service", "apply", getDefinitionPath("inst1.yml", service))
+
+				Convey("Then the service should be created", func() {
+					msg, err := waitMsg(inCreateSub)
+					So(err, ShouldBeNil)
+
+					event := instanceEvent{}
+					json.Unmarshal([]byte(strings.Replace(string(msg.Data), service, "<service>", -1)), &event)
+					event.Service = ""
+					events[d] = event
+
+					s, err := getService(service)
+					So(err, ShouldBeNil)
+					So(s.Status, ShouldEqual, "done")
+				})
+
+				sub.Unsubscribe()
+			})
+
+			Convey("When I list the datacenters with the "+d+" driver", func() {
+				o, _ := ernestVia(d, "", "datacenter", "list")
+
+				Convey("Then the default datacenter should be listed", func() {
+					So(o, ShouldContainSubstring, "fake")
+				})
+			})
+		}
+
+		Convey("When both drivers have applied the same definition", func() {
+			Convey("Then the connectors should have received the same instance", func() {
+				So(len(events), ShouldEqual, len(drivers))
+				So(events[httpDriver], ShouldResemble, events[cliDriver])
+			})
+		})
+	})
+}
+
+func TestAPITarget(t *testing.T) {
+	if os.Getenv("UAT_API_URL") != "" {
+		t.Skip("UAT_API_URL overrides the targeted api")
+	}
+
+	var calls []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path+" "+r.Header.Get("Authorization"))
+		if r.URL.Path == "/auth" {
+			w.Write([]byte(`{"token":"jwt"}`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer api.Close()
+
+	Convey("Given an api-gateway", t, func() {
+		Convey("When a user targets it and logs in with the http driver", func() {
+			calls = nil
+			target, err := apiAs("targeting", "target", api.URL+"/")
+			So(err, ShouldBeNil)
+			welcome, err := apiAs("targeting", "login", "--user", "targeting", "--password", "pwd")
+			So(err, ShouldBeNil)
+			list, err := apiAs("targeting", "datacenter", "list")
+			So(err, ShouldBeNil)
+
+			Convey("Then every call should go to the targeted api with the jwt", func() {
+				So(target, ShouldEqual, "Target set")
+				So(welcome, ShouldEqual, "Welcome back targeting")
+				So(list, ShouldEqual, "[]")
+				So(calls, ShouldResemble, []string{"POST /auth ", "GET /api/datacenters/ Bearer jwt"})
+			})
+		})
+
+		Convey("When a command is not supported", func() {
+			_, err := apiAs("targeting", "service", "monitor", "web")
+
+			Convey("Then it should be reported", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "service monitor web is not supported by the api driver")
+			})
+		})
+	})
+}
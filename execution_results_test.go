@@ -0,0 +1,131 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// executionCase answers the first execution of a definition with reports
+// and unmatched instances named without their datacenter and service prefix,
+// lines being what the cli must print for the failing instances and missing
+// what it must not print
+type executionCase struct {
+	description string
+	definition  string
+	execution   string
+	reports     map[string]report
+	unmatched   []string
+	status      string
+	lines       []string
+	missing     []string
+}
+
+var executionCases = []executionCase{
+	{
+		description: "every instance succeeds",
+		definition:  "novse12.yml",
+		execution:   "Execution web 1",
+		reports:     map[string]report{"web-1": {StdOut: "Tue Oct 18 10:00:00 UTC 2016"}},
+		status:      "done",
+		missing:     []string{"stderr", "return code"},
+	},
+	{
+		description: "the only instance fails",
+		definition:  "novse12.yml",
+		execution:   "Execution web 1",
+		reports:     map[string]report{"web-1": {Code: 1, StdErr: "date: invalid date 'yesterday'"}},
+		status:      "errored",
+		lines:       []string{"stderr: date: invalid date 'yesterday'", "return code: 1"},
+	},
+	{
+		description: "one of two instances fails",
+		definition:  "novse13.yml",
+		execution:   "Execution web 1",
+		reports: map[string]report{
+			"web-1": {StdOut: "Tue Oct 18 10:00:00 UTC 2016"},
+			"web-2": {Code: 127, StdErr: "sh: 1: date: not found"},
+		},
+		status:  "errored",
+		lines:   []string{"stderr: sh: 1: date: not found", "return code: 127"},
+		missing: []string{"Tue Oct 18 10:00:00 UTC 2016"},
+	},
+	{
+		description: "an instance is not matched",
+		definition:  "novse13.yml",
+		execution:   "Execution web 1",
+		unmatched:   []string{"web-2"},
+		status:      "errored",
+		missing:     []string{"stderr", "return code"},
+	},
+}
+
+// fakeResult prefixes the instance names of a case with the ones of service
+func (c executionCase) fakeResult(service string) fakeExecution {
+	prefix := "fake-" + service + "-"
+	result := fakeExecution{Reports: make(map[string]report)}
+	for name, r := range c.reports {
+		result.Reports[prefix+name] = r
+	}
+	for _, name := range c.unmatched {
+		result.Unmatched = append(result.Unmatched, prefix+name)
+	}
+	return result
+}
+
+func TestExecutionResults(t *testing.T) {
+	var suffix = strconv.Itoa(rand.Intn(9999999))
+
+	if !executionsByHarness {
+		t.Skip("configured execution results need UAT_EXECUTIONS=harness")
+	}
+
+	basicSetup("vcloud")
+
+	Convey("Given the salt executions are answered with configured results", t, func() {
+		for i, c := range executionCases {
+			c := c
+			service := "exres" + strconv.Itoa(i) + suffix
+
+			Convey("When I apply "+c.definition+" and "+c.description, func() {
+				responder, err := respondExecutions(service, map[string]fakeExecution{
+					c.execution: c.fakeResult(service),
+				}, nil)
+				So(err, ShouldBeNil)
+
+				o, _ := ernest("service", "apply", getDefinitionPath(c.definition, service))
+				responder.Stop()
+
+				Convey("Then the service should be "+c.status, func() {
+					So(len(responder.Answered()), ShouldBeGreaterThan, 0)
+					for _, ex := range responder.Answered() {
+						So(ex.ServiceName, ShouldEqual, service)
+					}
+
+					s, err := getService(service)
+					So(err, ShouldBeNil)
+					So(s.Status, ShouldEqual, c.status)
+
+					Info("And the cli should report the failing instances", " ", 8)
+					printed := make(map[string]bool)
+					for _, l := range outputLines(o) {
+						printed[strings.TrimSpace(l)] = true
+					}
+					for _, line := range c.lines {
+						So(printed[line], ShouldBeTrue)
+					}
+					for _, fragment := range c.missing {
+						So(o, ShouldNotContainSubstring, fragment)
+					}
+				})
+			})
+		}
+	})
+}
@@ -0,0 +1,210 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// targetExecution is an execution Ernest must send, with the instances it
+// must list or the glob it must target, and the ones they must resolve to,
+// named without their datacenter and service prefix
+type targetExecution struct {
+	Name    string
+	Targets []string
+	Glob    string
+	Matched []string
+}
+
+// target returns the execution target Ernest must send
+func (e targetExecution) target(prefix string) string {
+	if e.Glob != "" {
+		return e.Glob
+	}
+	return "list:" + strings.Join(prefixed(prefix, e.Targets), ",")
+}
+
+// targetStep is a definition applied and the executions it must send
+type targetStep struct {
+	Definition string
+	Executions []targetExecution
+}
+
+// scalingSteps scale the web group up and down, executions targeting the
+// instances of the group as it is on each step
+var scalingSteps = []targetStep{
+	{"novse12.yml", []targetExecution{{"Execution web 1", []string{"web-1"}, "", []string{"web-1"}}}},
+	{"novse13.yml", []targetExecution{{"Execution web 1", []string{"web-2"}, "", []string{"web-2"}}}},
+	{"novse14.yml", []targetExecution{{"Execution web 1", []string{"web-1", "web-2"}, "", []string{"web-1", "web-2"}}}},
+	{"novse15.yml", []targetExecution{{"Execution db 1", []string{"db-1"}, "", []string{"db-1"}}}},
+	{"novse16.yml", nil},
+}
+
+// groupSteps target whole groups, web never matching the webapp instances
+// its name prefixes, globs matching the instances of other groups or none,
+// and a group with no instances matching nothing
+var groupSteps = []targetStep{
+	{"target1.yml", []targetExecution{
+		{"Execution batch 1", nil, "*-web-*", []string{"web-1", "web-2"}},
+		{"Execution report 1", nil, "*-db-*", nil},
+		{"Execution web 1", []string{"web-1", "web-2"}, "", []string{"web-1", "web-2"}},
+		{"Execution webapp 1", []string{"webapp-1"}, "", []string{"webapp-1"}},
+	}},
+	{"target2.yml", []targetExecution{{"Execution cache 1", nil, "", nil}}},
+}
+
+// liveInstances returns the instances created and not deleted on a
+// timeline, with prefix removed from their names
+func liveInstances(tl *timeline, prefix string) []string {
+	live := make(map[string]bool)
+	for _, e := range tl.Entries() {
+		i := instanceEvent{}
+		json.Unmarshal(e.Data, &i)
+		if !strings.HasPrefix(i.InstanceName, prefix) {
+			continue
+		}
+		live[i.InstanceName] = strings.HasPrefix(e.Subject, "instance.create.")
+	}
+
+	var names []string
+	for name, alive := range live {
+		if alive {
+			names = append(names, strings.TrimPrefix(name, prefix))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func prefixed(prefix string, names []string) []string {
+	var p []string
+	for _, name := range names {
+		p = append(p, prefix+name)
+	}
+	return p
+}
+
+// checkTargets applies steps on service, asserting the executions Ernest
+// sends and the instances their targets resolve to among the ones it
+// created, as salt resolves them
+func checkTargets(service string, steps []targetStep, instances *timeline) {
+	prefix := "fake-" + service + "-"
+	live := func() []string {
+		return prefixed(prefix, liveInstances(instances, prefix))
+	}
+
+	for _, step := range steps {
+		step := step
+
+		Convey("When I apply a valid "+step.Definition+" definition", func() {
+			tl, err := recordTimeline("execution.create.fake")
+			So(err, ShouldBeNil)
+
+			// the harness answers the executions when the fake connector is
+			// not running, resolving their targets the same way
+			var responder *executionResponder
+			if executionsByHarness {
+				responder, err = respondExecutions(service, nil, live)
+				So(err, ShouldBeNil)
+			}
+
+			ernest("service", "apply", getDefinitionPath(step.Definition, service))
+			tl.Stop()
+			if responder != nil {
+				responder.Stop()
+			}
+
+			Convey("Then the executions should target and resolve to the expected instances", func() {
+				var executions []executionEvent
+				for _, e := range tl.Entries() {
+					ex := executionEvent{}
+					json.Unmarshal(e.Data, &ex)
+					if ex.ServiceName == service && !strings.HasPrefix(ex.Name, "Cleanup Bootstrap ") {
+						executions = append(executions, ex)
+					}
+				}
+				sort.Slice(executions, func(i, j int) bool {
+					return executions[i].Name < executions[j].Name
+				})
+
+				So(len(executions), ShouldEqual, len(step.Executions))
+				for i, expected := range step.Executions {
+					if i >= len(executions) {
+						break
+					}
+					ex := executions[i]
+					So(ex.Name, ShouldEqual, expected.Name)
+					So(ex.ExecutionTarget, ShouldEqual, expected.target(prefix))
+
+					matched := matchTarget(ex.ExecutionTarget, live())
+					sort.Strings(matched)
+					So(matched, ShouldResemble, prefixed(prefix, expected.Matched))
+				}
+			})
+		})
+	}
+}
+
+func TestMatchTarget(t *testing.T) {
+	instances := []string{"fake-svc-web-1", "fake-svc-web-2", "fake-svc-webapp-1", "fake-svc-db-1"}
+
+	cases := []struct {
+		target  string
+		matched []string
+	}{
+		{"list:fake-svc-web-1", []string{"fake-svc-web-1"}},
+		{"list:fake-svc-web-1, fake-svc-db-1", []string{"fake-svc-web-1", "fake-svc-db-1"}},
+		{"list:fake-svc-web", nil},
+		{"list:fake-svc-web-3", nil},
+		{"list:", nil},
+		{"*-web-*", []string{"fake-svc-web-1", "fake-svc-web-2"}},
+		{"*-web*", []string{"fake-svc-web-1", "fake-svc-web-2", "fake-svc-webapp-1"}},
+		{"fake-svc-web-[2-9]", []string{"fake-svc-web-2"}},
+		{"fake-svc-db-?", []string{"fake-svc-db-1"}},
+		{"fake-svc-web", nil},
+		{"*-cache-*", nil},
+		{"*", instances},
+	}
+
+	Convey("Given the instances of a service", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When an execution targets "+c.target, func() {
+				matched := matchTarget(c.target, instances)
+
+				Convey("Then it should match the instances salt matches", func() {
+					So(matched, ShouldResemble, c.matched)
+				})
+			})
+		}
+	})
+}
+
+func TestExecutionTargets(t *testing.T) {
+	var suffix = strconv.Itoa(rand.Intn(9999999))
+
+	basicSetup("vcloud")
+
+	instances, err := recordTimeline("instance.create.vcloud-fake", "instance.delete.vcloud-fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer instances.Stop()
+
+	Convey("Given the salt executions of a scaled group are resolved against the service instances", t, func() {
+		checkTargets("targets"+suffix, scalingSteps, instances)
+	})
+
+	Convey("Given the salt executions of several groups are resolved against the service instances", t, func() {
+		checkTargets("groups"+suffix, groupSteps, instances)
+	})
+}
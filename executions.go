@@ -0,0 +1,222 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats"
+)
+
+// executionsByHarness is set with UAT_EXECUTIONS=harness when the stack runs
+// without the fake execution connector, the harness answering every
+// execution.create.fake itself so configured results never race the
+// connector ones
+var executionsByHarness = os.Getenv("UAT_EXECUTIONS") == "harness"
+
+// fakeExecution is the result given to an execution, reports being keyed
+// by instance name and unmatched being the targeted instances left out of
+// the matched ones
+type fakeExecution struct {
+	Reports   map[string]report
+	Unmatched []string
+}
+
+// executionResponder answers the executions of a service with configured
+// results
+type executionResponder struct {
+	sync.Mutex
+	service   string
+	results   map[string]fakeExecution
+	instances func() []string
+	answered  []executionEvent
+}
+
+// responders holds the responder of every service whose executions are
+// answered with configured results
+var responders = struct {
+	sync.Mutex
+	services map[string]*executionResponder
+	sub      *nats.Subscription
+}{services: make(map[string]*executionResponder)}
+
+// answerExecutions starts answering execution.create.fake in place of the
+// fake connector, executions of services with no responder succeeding on
+// their listed targets
+func answerExecutions() error {
+	sub, err := n.Subscribe("execution.create.fake", func(msg *nats.Msg) {
+		ex := executionEvent{}
+		json.Unmarshal(msg.Data, &ex)
+
+		responders.Lock()
+		r, ok := responders.services[ex.ServiceName]
+		responders.Unlock()
+		if !ok {
+			r = &executionResponder{}
+		}
+		r.respond(msg)
+	})
+	if err != nil {
+		return err
+	}
+
+	responders.Lock()
+	responders.sub = sub
+	responders.Unlock()
+	return nil
+}
+
+// respondExecutions answers the executions of service with results keyed
+// by execution name, executions with no result succeeding on every
+// instance. Targets are resolved against instances, when given, or taken as
+// the listed instance names otherwise. The harness must be the one
+// answering executions, or the fake connector would answer them too
+func respondExecutions(service string, results map[string]fakeExecution, instances func() []string) (*executionResponder, error) {
+	responders.Lock()
+	defer responders.Unlock()
+
+	if responders.sub == nil {
+		return nil, errors.New("executions are answered by the fake connector, run the stack without it and set UAT_EXECUTIONS=harness")
+	}
+	if _, ok := responders.services[service]; ok {
+		return nil, errors.New("the executions of " + service + " are already answered")
+	}
+
+	r := &executionResponder{service: service, results: results, instances: instances}
+	responders.services[service] = r
+	return r, nil
+}
+
+// Stop leaves the executions of the service to the default answer
+func (r *executionResponder) Stop() {
+	responders.Lock()
+	defer responders.Unlock()
+	if responders.services[r.service] == r {
+		delete(responders.services, r.service)
+	}
+}
+
+// Answered returns every execution answered, with the results given
+func (r *executionResponder) Answered() []executionEvent {
+	r.Lock()
+	defer r.Unlock()
+	return append([]executionEvent{}, r.answered...)
+}
+
+func (r *executionResponder) respond(msg *nats.Msg) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(msg.Data, &payload); err != nil {
+		return
+	}
+	ex := executionEvent{}
+	json.Unmarshal(msg.Data, &ex)
+
+	result := r.results[ex.Name]
+	var matched []string
+	if r.instances != nil {
+		matched = matchTarget(ex.ExecutionTarget, r.instances())
+	} else {
+		matched = executionTargets(ex.ExecutionTarget)
+	}
+	for _, u := range result.Unmatched {
+		matched = without(matched, u)
+	}
+
+	status := "success"
+	reports := []report{}
+	for _, instance := range matched {
+		rp, ok := result.Reports[instance]
+		if !ok {
+			rp = report{StdOut: "ok"}
+		}
+		rp.Instance = instance
+		if rp.Code != 0 {
+			status = "failed"
+		}
+		reports = append(reports, rp)
+	}
+	if len(result.Unmatched) > 0 {
+		status = "partial"
+	}
+
+	payload["execution_results"] = map[string]interface{}{"reports": reports}
+	payload["execution_matched_instances"] = matched
+	payload["execution_status"] = status
+
+	subject := replySubject(msg.Subject, "done")
+	if status != "success" {
+		subject = replySubject(msg.Subject, "error")
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	json.Unmarshal(data, &ex)
+
+	r.Lock()
+	r.answered = append(r.answered, ex)
+	r.Unlock()
+
+	n.Publish(subject, data)
+}
+
+// executionTargets returns the instance names of a list: target, any
+// other target being returned as is
+func executionTargets(target string) []string {
+	if !strings.HasPrefix(target, "list:") {
+		return []string{target}
+	}
+
+	var targets []string
+	for _, t := range strings.Split(strings.TrimPrefix(target, "list:"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// matchTarget resolves an execution target the way salt does, list:
+// targets matching the listed names and any other target being a glob
+func matchTarget(target string, instances []string) []string {
+	var matched []string
+
+	if strings.HasPrefix(target, "list:") {
+		listed := executionTargets(target)
+		for _, i := range instances {
+			for _, l := range listed {
+				if i == l {
+					matched = append(matched, i)
+					break
+				}
+			}
+		}
+		return matched
+	}
+
+	for _, i := range instances {
+		if ok, _ := path.Match(target, i); ok {
+			matched = append(matched, i)
+		}
+	}
+
+	return matched
+}
+
+func without(list []string, item string) []string {
+	var kept []string
+	for _, l := range list {
+		if l != item {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
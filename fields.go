@@ -0,0 +1,235 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An expected message gives the value of some fields of a message published
+// on its subject, fields being named by a path such as rules[0].source_ip,
+// where keys match in any case, and len(path) being the length of a list.
+// A field missing from the message fails whatever its expected value, so a
+// mistyped path is never read as an empty one. A value can also be given
+// as:
+//
+//   {"not": value}       any value but the given one
+//   {"contains": text}   a string holding text
+//
+// Placeholders such as {service} on string values are replaced by their
+// values before matching.
+
+// expectedMessage is a message a step must publish on subject
+type expectedMessage struct {
+	Subject string                 `json:"subject"`
+	Fields  map[string]interface{} `json:"fields"`
+}
+
+var fieldStep = regexp.MustCompile(`^([^\[\]]*)((?:\[\d+\])*)$`)
+var fieldIndex = regexp.MustCompile(`\[(\d+)\]`)
+
+// fieldValue returns the value found on path in a decoded message
+func fieldValue(v interface{}, path string) (interface{}, bool) {
+	if strings.HasPrefix(path, "len(") && strings.HasSuffix(path, ")") {
+		inner, ok := fieldValue(v, path[4:len(path)-1])
+		switch x := inner.(type) {
+		case []interface{}:
+			return float64(len(x)), true
+		case map[string]interface{}:
+			return float64(len(x)), true
+		case string:
+			return float64(len(x)), true
+		}
+		return float64(0), ok
+	}
+
+	for _, step := range strings.Split(path, ".") {
+		m := fieldStep.FindStringSubmatch(step)
+		if m == nil {
+			return nil, false
+		}
+		if m[1] != "" {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if v, ok = fieldKey(obj, m[1]); !ok {
+				return nil, false
+			}
+		}
+		for _, i := range fieldIndex.FindAllStringSubmatch(m[2], -1) {
+			list, ok := v.([]interface{})
+			index, _ := strconv.Atoi(i[1])
+			if !ok || index >= len(list) {
+				return nil, false
+			}
+			v = list[index]
+		}
+	}
+
+	return v, true
+}
+
+// fieldKey looks a key up on an object, in any case when it is not found
+// as given
+func fieldKey(obj map[string]interface{}, key string) (interface{}, bool) {
+	if v, ok := obj[key]; ok {
+		return v, true
+	}
+	for k, v := range obj {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// expandVars replaces the placeholders of the string values of an expected
+// field by their values
+func expandVars(expected interface{}, vars map[string]string) interface{} {
+	switch x := expected.(type) {
+	case string:
+		return placeholderPattern.ReplaceAllStringFunc(x, func(p string) string {
+			if v, ok := vars[p[1:len(p)-1]]; ok {
+				return v
+			}
+			return p
+		})
+	case map[string]interface{}:
+		expanded := make(map[string]interface{}, len(x))
+		for k, v := range x {
+			expanded[k] = expandVars(v, vars)
+		}
+		return expanded
+	}
+	return expected
+}
+
+// fieldMismatch compares a field value with the expected one, returning
+// why they differ
+func fieldMismatch(path string, expected, got interface{}, found bool) string {
+	if !found {
+		return "expected " + path + " but the message has no such field"
+	}
+
+	if cond, ok := expected.(map[string]interface{}); ok {
+		if v, ok := cond["not"]; ok {
+			if fmt.Sprint(got) == fmt.Sprint(v) {
+				return fmt.Sprintf("expected %s not to be %q", path, redact(fmt.Sprint(v)))
+			}
+			return ""
+		}
+		if v, ok := cond["contains"]; ok {
+			s, _ := got.(string)
+			if !strings.Contains(s, fmt.Sprint(v)) {
+				return fmt.Sprintf("expected %s to contain %q but got %q", path, redact(fmt.Sprint(v)), redact(s))
+			}
+			return ""
+		}
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		return fmt.Sprintf("expected %s to be %q but got %q", path, redact(fmt.Sprint(expected)), redact(fmt.Sprint(got)))
+	}
+	return ""
+}
+
+// messageMismatches returns why a message does not hold the expected
+// fields, in field order
+func messageMismatches(e expectedMessage, data []byte, vars map[string]string) []string {
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return []string{"invalid message: " + err.Error()}
+	}
+
+	var paths []string
+	for p := range e.Fields {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var mismatches []string
+	for _, p := range paths {
+		got, found := fieldValue(decoded, p)
+		if m := fieldMismatch(p, expandVars(e.Fields[p], vars), got, found); m != "" {
+			mismatches = append(mismatches, m)
+		}
+	}
+	return mismatches
+}
+
+// matchMessages pairs every expected message with a different recorded one
+// holding its fields, returning why the expected messages left with no pair
+// do not match the closest message of their subject
+func matchMessages(expected []expectedMessage, recorded []timelineEntry, vars map[string]string) []string {
+	mismatches := make([][][]string, len(expected))
+	for i, e := range expected {
+		mismatches[i] = make([][]string, len(recorded))
+		for j, r := range recorded {
+			if r.Subject != e.Subject {
+				mismatches[i][j] = []string{"subject"}
+				continue
+			}
+			mismatches[i][j] = messageMismatches(e, r.Data, vars)
+		}
+	}
+
+	used := make([]bool, len(recorded))
+	var pair func(i int) bool
+	pair = func(i int) bool {
+		if i == len(expected) {
+			return true
+		}
+		for j := range recorded {
+			if !used[j] && len(mismatches[i][j]) == 0 {
+				used[j] = true
+				if pair(i + 1) {
+					return true
+				}
+				used[j] = false
+			}
+		}
+		return false
+	}
+	if pair(0) {
+		return nil
+	}
+
+	var errs []string
+	for i, e := range expected {
+		closest := -1
+		matched := false
+		for j, r := range recorded {
+			if r.Subject != e.Subject {
+				continue
+			}
+			if len(mismatches[i][j]) == 0 {
+				matched = true
+				break
+			}
+			if closest < 0 || len(mismatches[i][j]) < len(mismatches[i][closest]) {
+				closest = j
+			}
+		}
+		switch {
+		case matched:
+		case closest < 0:
+			errs = append(errs, "no "+e.Subject+" message received")
+		default:
+			for _, m := range mismatches[i][closest] {
+				errs = append(errs, e.Subject+": "+m)
+			}
+		}
+	}
+	if len(errs) == 0 {
+		errs = append(errs, "the expected messages match fewer distinct messages than expected")
+	}
+	return errs
+}
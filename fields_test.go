@@ -0,0 +1,107 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMatchMessages(t *testing.T) {
+	recorded := []timelineEntry{
+		{Subject: "instance.update.vcloud-fake", Data: []byte(`{"name":"fake-svc-web-1","cpus":1,"disks":[]}`)},
+		{Subject: "instance.update.vcloud-fake", Data: []byte(`{"name":"fake-svc-web-2","cpus":2,"disks":[{"id":1,"size":10240}]}`)},
+		{Subject: "firewall.update.vcloud-fake", Data: []byte(`{"router_name":"vse2","datacenter_password":"s3cr3t","rules":[{"source_ip":"internal","protocol":"tcp"}]}`)},
+	}
+	vars := map[string]string{"service": "svc", "default_pwd": "s3cr3t"}
+	registerSecret("test password", "s3cr3t")
+
+	cases := []struct {
+		description string
+		expected    string
+		errs        []string
+	}{
+		{
+			"the fields of two messages on the same subject",
+			`[{"subject":"instance.update.vcloud-fake","fields":{"name":"fake-{service}-web-2","cpus":2,"len(disks)":1,"disks[0].size":10240}},
+			  {"subject":"instance.update.vcloud-fake","fields":{"name":"fake-{service}-web-1","len(disks)":0}}]`,
+			nil,
+		},
+		{
+			"keys in another case and conditions",
+			`[{"subject":"firewall.update.vcloud-fake","fields":{"Router_Name":"vse2","datacenter_password":"{default_pwd}","rules[0].SOURCE_IP":"internal","len(rules)":1,"rules[0].protocol":{"not":"udp"},"router_name":{"contains":"vse"}}}]`,
+			nil,
+		},
+		{
+			"two expected messages a single message holds",
+			`[{"subject":"firewall.update.vcloud-fake","fields":{"router_name":"vse2"}},
+			  {"subject":"firewall.update.vcloud-fake","fields":{"len(rules)":1}}]`,
+			[]string{"the expected messages match fewer distinct messages than expected"},
+		},
+		{
+			"a field with another value",
+			`[{"subject":"instance.update.vcloud-fake","fields":{"name":"fake-{service}-web-2","cpus":4}}]`,
+			[]string{`instance.update.vcloud-fake: expected cpus to be "4" but got "2"`},
+		},
+		{
+			"fields the message does not hold",
+			`[{"subject":"firewall.update.vcloud-fake","fields":{"router_ip":"","len(nats)":0,"rules[1].protocol":{"not":"udp"}}}]`,
+			[]string{
+				"firewall.update.vcloud-fake: expected len(nats) but the message has no such field",
+				"firewall.update.vcloud-fake: expected router_ip but the message has no such field",
+				"firewall.update.vcloud-fake: expected rules[1].protocol but the message has no such field",
+			},
+		},
+		{
+			"a secret with another value",
+			`[{"subject":"firewall.update.vcloud-fake","fields":{"datacenter_password":"other"}}]`,
+			[]string{`firewall.update.vcloud-fake: expected datacenter_password to be "other" but got "[redacted test password]"`},
+		},
+		{
+			"a subject with no message",
+			`[{"subject":"nat.update.vcloud-fake","fields":{}}]`,
+			[]string{"no nat.update.vcloud-fake message received"},
+		},
+	}
+
+	Convey("Given the messages recorded during a step", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When I expect "+c.description, func() {
+				var expected []expectedMessage
+				So(json.Unmarshal([]byte(c.expected), &expected), ShouldBeNil)
+
+				Convey("Then only the unmet expectations should be reported", func() {
+					errs := matchMessages(expected, recorded, vars)
+					if c.errs == nil {
+						So(errs, ShouldBeEmpty)
+						return
+					}
+					So(errs, ShouldResemble, c.errs)
+				})
+			})
+		}
+	})
+}
+
+func TestStepSubjects(t *testing.T) {
+	Convey("Given a step expecting messages and their order", t, func() {
+		step := scenarioStep{Expect: expectation{
+			Events:   map[string]int{"router.create.vcloud-fake": 1, "instance.create.vcloud-fake": 1},
+			Messages: []expectedMessage{{Subject: "router.create.vcloud-fake"}},
+			Before:   [][2]string{{"router.create.*", "network.create.*"}},
+		}}
+
+		Convey("When I list the subjects it records", func() {
+			subjects := step.subjects()
+
+			Convey("Then each message should only be recorded once", func() {
+				So(subjects, ShouldResemble, []string{"instance.create.vcloud-fake", "network.create.*", "router.create.*"})
+			})
+		})
+	})
+}
@@ -0,0 +1,152 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var fwEndpoints = []string{"internal", "external", "any", "10.10.0.0/24", "10.10.0.5"}
+var fwPorts = []string{"any", "22", "8000-8010"}
+var fwProtocols = []string{"tcp", "udp", "icmp", "any"}
+
+type invalidRule struct {
+	description string
+	message     string
+	rule        fwrule
+}
+
+// invalidRules are rejected while validating the definition, with the
+// message the cli prints
+var invalidRules = []invalidRule{
+	{"an unknown source keyword", "Router rule source (intranet) is not valid", fwrule{SourceIP: "intranet", SourcePort: "any", DestinationIP: "internal", DestinationPort: "any", Protocol: "tcp"}},
+	{"an invalid destination ip", "Router rule destination (10.10.0.300) is not valid", fwrule{SourceIP: "internal", SourcePort: "any", DestinationIP: "10.10.0.300", DestinationPort: "any", Protocol: "tcp"}},
+	{"an invalid source cidr", "Router rule source (10.10.0.0/33) is not valid", fwrule{SourceIP: "10.10.0.0/33", SourcePort: "any", DestinationIP: "internal", DestinationPort: "any", Protocol: "tcp"}},
+	{"an out of range port", "Router rule from port (70000) is out of range [1 - 65535]", fwrule{SourceIP: "internal", SourcePort: "70000", DestinationIP: "internal", DestinationPort: "any", Protocol: "tcp"}},
+	{"a reversed port range", "Router rule to port range (8010-8000) is not valid", fwrule{SourceIP: "internal", SourcePort: "any", DestinationIP: "internal", DestinationPort: "8010-8000", Protocol: "tcp"}},
+	{"an unknown protocol", "Router rule protocol (sctp) is not valid", fwrule{SourceIP: "internal", SourcePort: "any", DestinationIP: "internal", DestinationPort: "any", Protocol: "sctp"}},
+}
+
+// firewallMatrix returns a rule for every combination of endpoint, port
+// and protocol. icmp has no ports, so it is only combined with any ports
+func firewallMatrix() []fwrule {
+	var rules []fwrule
+
+	for _, source := range fwEndpoints {
+		for _, destination := range fwEndpoints {
+			for _, from := range fwPorts {
+				for _, to := range fwPorts {
+					for _, protocol := range fwProtocols {
+						if protocol == "icmp" && (from != "any" || to != "any") {
+							continue
+						}
+						rules = append(rules, fwrule{
+							SourceIP:        source,
+							SourcePort:      from,
+							DestinationIP:   destination,
+							DestinationPort: to,
+							Protocol:        protocol,
+						})
+					}
+				}
+			}
+		}
+	}
+
+	return rules
+}
+
+func routerDefinition(router string, rules []fwrule) string {
+	def := "---\nname: my_service\ndatacenter: r3-dc2\nbootstrapping: none\n\nrouters:\n"
+	def += "  - name: " + router + "\n    rules:\n"
+	for i, r := range rules {
+		def += fmt.Sprintf("    - name: rule_%d\n", i)
+		def += fmt.Sprintf("      source: '%s'\n", r.SourceIP)
+		def += fmt.Sprintf("      from_port: '%s'\n", r.SourcePort)
+		def += fmt.Sprintf("      destination: '%s'\n", r.DestinationIP)
+		def += fmt.Sprintf("      to_port: '%s'\n", r.DestinationPort)
+		def += fmt.Sprintf("      protocol: '%s'\n", r.Protocol)
+		def += "      action: allow\n\n"
+	}
+	def += "    networks:\n      - name: web\n        subnet: 10.1.0.0/24\n"
+
+	return def
+}
+
+func TestFirewallConformance(t *testing.T) {
+	var service = "fwc"
+
+	service = service + strconv.Itoa(rand.Intn(9999999))
+
+	fiCreateSub := make(chan *nats.Msg, 1)
+	rules := firewallMatrix()
+	basicSetup("vcloud")
+
+	Convey("Given I have a configured ernest instance", t, func() {
+		Convey("When I apply a router with every firewall rule combination", func() {
+			subFi, _ := n.ChanSubscribe("firewall.create.vcloud-fake", fiCreateSub)
+
+			f := writeDefinition(routerDefinition("vse6", rules), service, "fake")
+
+			_, err := ernest("service", "apply", f)
+			Convey("Then it should configure every rule on router vse6", func() {
+				if err != nil {
+					log.Println(err.Error())
+				}
+
+				e := firewallEvent{}
+				msg, err := waitMsg(fiCreateSub)
+				So(err, ShouldBeNil)
+				json.Unmarshal(msg.Data, &e)
+
+				So(e.RouterName, ShouldEqual, "vse6")
+				So(e.RouterType, ShouldEqual, "vcloud-fake")
+				So(len(e.Rules), ShouldEqual, len(rules))
+
+				Info("And each rule should be translated unchanged", " ", 8)
+				for i := 0; i < len(rules) && i < len(e.Rules); i++ {
+					So(e.Rules[i], ShouldResemble, rules[i])
+				}
+			})
+
+			subFi.Unsubscribe()
+		})
+
+		for i, c := range invalidRules {
+			name := service + "i" + strconv.Itoa(i)
+			c := c
+
+			Convey("When I apply a router rule with "+c.description, func() {
+				tl, err := recordTimeline("firewall.*.*", "service.create", "service.create.*")
+				So(err, ShouldBeNil)
+
+				f := writeDefinition(routerDefinition("vse6", []fwrule{c.rule}), name, "fake")
+
+				o, _ := ernest("service", "apply", f)
+				Convey("Then it should be rejected by the cli", func() {
+					Info("And the error should be "+c.message, " ", 8)
+					So(o, ShouldContainSubstring, c.message)
+
+					Info("And no service nor firewall should be created", " ", 8)
+					time.Sleep(2 * time.Second)
+					tl.Stop()
+					So(tl.Entries(), ShouldBeEmpty)
+
+					_, err := getService(name)
+					So(err, ShouldNotBeNil)
+				})
+			})
+		}
+	})
+}
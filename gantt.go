@@ -0,0 +1,245 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// minIdle is the shortest gap with no connector call in flight reported as
+// idle time
+const minIdle = 500 * time.Millisecond
+
+// span is a connector call, from its request to its response
+type span struct {
+	UUID   string
+	Batch  string
+	Phase  string
+	Name   string
+	Start  time.Time
+	End    time.Time
+	Failed bool
+	Open   bool
+}
+
+type interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Spans pairs every recorded connector request with its response, requests
+// with no response are left open until the last recorded message
+func (t *timeline) Spans() []span {
+	var spans []span
+	index := make(map[string]int)
+	entries := t.Entries()
+
+	for _, e := range entries {
+		env := e.envelope()
+		if env.UUID == "" {
+			continue
+		}
+
+		if e.isResponse() {
+			if i, ok := index[env.UUID]; ok {
+				spans[i].End = e.Received
+				spans[i].Failed = strings.HasSuffix(e.Subject, ".error")
+				spans[i].Open = false
+			}
+			continue
+		}
+
+		if _, ok := index[env.UUID]; ok {
+			continue
+		}
+		name := env.Name
+		if name == "" {
+			name = e.Subject
+		}
+		index[env.UUID] = len(spans)
+		spans = append(spans, span{
+			UUID:  env.UUID,
+			Batch: env.BatchID,
+			Phase: e.phase(),
+			Name:  redact(name),
+			Start: e.Received,
+			Open:  true,
+		})
+	}
+
+	if len(entries) > 0 {
+		end := entries[len(entries)-1].Received
+		for i := range spans {
+			if spans[i].Open {
+				spans[i].End = end
+			}
+		}
+	}
+
+	return spans
+}
+
+// idle returns the periods between the first and the last span where no
+// connector call was in flight
+func idle(spans []span) []interval {
+	sorted := append([]span{}, spans...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var gaps []interval
+	var busy time.Time
+	for i, s := range sorted {
+		if i > 0 && s.Start.Sub(busy) >= minIdle {
+			gaps = append(gaps, interval{Start: busy, End: s.Start})
+		}
+		if s.End.After(busy) {
+			busy = s.End
+		}
+	}
+	return gaps
+}
+
+// parallelism returns the highest number of calls in flight at once
+func parallelism(spans []span) int {
+	max := 0
+	for _, s := range spans {
+		c := 0
+		for _, o := range spans {
+			if !o.Start.After(s.Start) && o.End.After(s.Start) {
+				c++
+			}
+		}
+		if c > max {
+			max = c
+		}
+	}
+	return max
+}
+
+// batchOrder groups spans by batch, batches sorted by their first call
+func batchOrder(spans []span) ([]string, map[string][]span) {
+	batches := make(map[string][]span)
+	var ids []string
+	for _, s := range spans {
+		if _, ok := batches[s.Batch]; !ok {
+			ids = append(ids, s.Batch)
+		}
+		batches[s.Batch] = append(batches[s.Batch], s)
+	}
+	return ids, batches
+}
+
+// renderMermaid writes a mermaid gantt chart of the spans
+func renderMermaid(w io.Writer, title string, spans []span) {
+	fmt.Fprintln(w, "gantt")
+	fmt.Fprintf(w, "    title %s\n", mermaidText(title))
+	fmt.Fprintln(w, "    dateFormat x")
+	fmt.Fprintln(w, "    axisFormat %H:%M:%S")
+
+	ids, batches := batchOrder(spans)
+	for _, id := range ids {
+		fmt.Fprintf(w, "    section batch %s (%d parallel)\n", mermaidText(id), parallelism(batches[id]))
+		for _, s := range batches[id] {
+			status := "done"
+			if s.Failed {
+				status = "crit"
+			} else if s.Open {
+				status = "active"
+			}
+			fmt.Fprintf(w, "    %s %s :%s, %d, %d\n", s.Phase, mermaidText(s.Name), status, millis(s.Start), millis(s.End))
+		}
+	}
+
+	gaps := idle(spans)
+	if len(gaps) > 0 {
+		fmt.Fprintln(w, "    section idle")
+		for _, g := range gaps {
+			fmt.Fprintf(w, "    idle %s :%d, %d\n", g.End.Sub(g.Start), millis(g.Start), millis(g.End))
+		}
+	}
+}
+
+// renderHTML writes a self contained html gantt chart of the spans
+func renderHTML(w io.Writer, title string, spans []span) {
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(title))
+	fmt.Fprintln(w, "<style>")
+	fmt.Fprintln(w, "body { font-family: sans-serif; font-size: 12px; }")
+	fmt.Fprintln(w, ".row { position: relative; height: 18px; border-bottom: 1px solid #eee; }")
+	fmt.Fprintln(w, ".label { position: absolute; left: 0; width: 340px; overflow: hidden; white-space: nowrap; }")
+	fmt.Fprintln(w, ".lane { position: absolute; left: 350px; right: 0; top: 2px; bottom: 2px; }")
+	fmt.Fprintln(w, ".bar { position: absolute; top: 0; bottom: 0; background: #4a90d9; }")
+	fmt.Fprintln(w, ".failed { background: #d9534f; }")
+	fmt.Fprintln(w, ".open { background: #f0ad4e; }")
+	fmt.Fprintln(w, ".idle { position: absolute; top: 0; bottom: 0; background: repeating-linear-gradient(45deg, #ddd, #ddd 4px, #fff 4px, #fff 8px); }")
+	fmt.Fprintln(w, "</style>\n</head>\n<body>")
+	fmt.Fprintf(w, "<h1>%s</h1>\n", html.EscapeString(title))
+
+	if len(spans) == 0 {
+		fmt.Fprintln(w, "<p>No connector calls recorded</p>\n</body>\n</html>")
+		return
+	}
+
+	start, end := spans[0].Start, spans[0].End
+	for _, s := range spans {
+		if s.Start.Before(start) {
+			start = s.Start
+		}
+		if s.End.After(end) {
+			end = s.End
+		}
+	}
+	total := end.Sub(start)
+	if total <= 0 {
+		total = time.Millisecond
+	}
+	position := func(from, to time.Time) string {
+		left := float64(from.Sub(start)) / float64(total) * 100
+		width := float64(to.Sub(from)) / float64(total) * 100
+		return fmt.Sprintf("left: %.2f%%; width: %.2f%%", left, width)
+	}
+
+	fmt.Fprintf(w, "<p>%d calls in %s</p>\n", len(spans), total)
+
+	ids, batches := batchOrder(spans)
+	for _, id := range ids {
+		fmt.Fprintf(w, "<h2>batch %s, %d parallel</h2>\n", html.EscapeString(id), parallelism(batches[id]))
+		for _, s := range batches[id] {
+			class := "bar"
+			if s.Failed {
+				class += " failed"
+			} else if s.Open {
+				class += " open"
+			}
+			label := html.EscapeString(s.Phase + " " + s.Name)
+			fmt.Fprintf(w, "<div class=\"row\"><div class=\"label\" title=\"%s\">%s</div>", label, label)
+			fmt.Fprintf(w, "<div class=\"lane\"><div class=\"%s\" style=\"%s\" title=\"%s\"></div></div></div>\n", class, position(s.Start, s.End), s.End.Sub(s.Start))
+		}
+	}
+
+	gaps := idle(spans)
+	if len(gaps) > 0 {
+		fmt.Fprintln(w, "<h2>idle</h2>")
+		fmt.Fprint(w, "<div class=\"row\"><div class=\"label\">workflow idle</div><div class=\"lane\">")
+		for _, g := range gaps {
+			fmt.Fprintf(w, "<div class=\"idle\" style=\"%s\" title=\"%s\"></div>", position(g.Start, g.End), g.End.Sub(g.Start))
+		}
+		fmt.Fprintln(w, "</div></div>")
+	}
+
+	fmt.Fprintln(w, "</body>\n</html>")
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// mermaidText strips the characters mermaid uses as separators
+func mermaidText(s string) string {
+	return strings.NewReplacer(":", " ", "#", " ", ";", " ", "\n", " ").Replace(s)
+}
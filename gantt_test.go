@@ -0,0 +1,180 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var ganttStart = time.Date(2016, 10, 19, 10, 0, 0, 0, time.UTC)
+
+// at returns the time ms milliseconds after the start of the gantt tests
+func at(ms int) time.Time {
+	return ganttStart.Add(time.Duration(ms) * time.Millisecond)
+}
+
+func ganttEntry(subject, uuid, batch, name string, ms int) timelineEntry {
+	data, _ := json.Marshal(envelope{UUID: uuid, BatchID: batch, Name: name})
+	return timelineEntry{Subject: subject, Data: data, Received: at(ms)}
+}
+
+func TestSpans(t *testing.T) {
+	cases := []struct {
+		description string
+		entries     []timelineEntry
+		spans       []span
+	}{
+		{
+			"a request and its response",
+			[]timelineEntry{
+				ganttEntry("instance.create.aws-fake", "1", "b1", "web-1", 0),
+				ganttEntry("instance.create.aws-fake.done", "1", "b1", "web-1", 300),
+			},
+			[]span{{UUID: "1", Batch: "b1", Phase: "instance.create", Name: "web-1", Start: at(0), End: at(300)}},
+		},
+		{
+			"a request answered with an error",
+			[]timelineEntry{
+				ganttEntry("network.create.aws-fake", "1", "b1", "web", 0),
+				ganttEntry("network.create.aws-fake.error", "1", "b1", "web", 200),
+			},
+			[]span{{UUID: "1", Batch: "b1", Phase: "network.create", Name: "web", Start: at(0), End: at(200), Failed: true}},
+		},
+		{
+			"a request with no response",
+			[]timelineEntry{
+				ganttEntry("instance.create.aws-fake", "1", "b1", "web-1", 0),
+				ganttEntry("instance.create.aws-fake", "2", "b1", "web-2", 100),
+				ganttEntry("instance.create.aws-fake.done", "2", "b1", "web-2", 400),
+			},
+			[]span{
+				{UUID: "1", Batch: "b1", Phase: "instance.create", Name: "web-1", Start: at(0), End: at(400), Open: true},
+				{UUID: "2", Batch: "b1", Phase: "instance.create", Name: "web-2", Start: at(100), End: at(400)},
+			},
+		},
+		{
+			"a request published twice",
+			[]timelineEntry{
+				ganttEntry("instance.create.aws-fake", "1", "b1", "web-1", 0),
+				ganttEntry("instance.create.aws-fake", "1", "b1", "web-1", 50),
+				ganttEntry("instance.create.aws-fake.done", "1", "b1", "web-1", 100),
+			},
+			[]span{{UUID: "1", Batch: "b1", Phase: "instance.create", Name: "web-1", Start: at(0), End: at(100)}},
+		},
+		{
+			"messages with no uuid and responses with no request",
+			[]timelineEntry{
+				ganttEntry("service.create", "", "", "s", 0),
+				ganttEntry("instance.create.aws-fake.done", "9", "b1", "web-1", 100),
+			},
+			nil,
+		},
+		{
+			"a request with no name",
+			[]timelineEntry{
+				ganttEntry("s3.create.aws-fake", "1", "b1", "", 0),
+				ganttEntry("s3.create.aws-fake.done", "1", "b1", "", 100),
+			},
+			[]span{{UUID: "1", Batch: "b1", Phase: "s3.create", Name: "s3.create.aws-fake", Start: at(0), End: at(100)}},
+		},
+	}
+
+	Convey("Given a timeline", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When it holds "+c.description, func() {
+				tl := &timeline{entries: c.entries}
+
+				Convey("Then it should pair every request with its response", func() {
+					So(tl.Spans(), ShouldResemble, c.spans)
+				})
+			})
+		}
+	})
+}
+
+func TestIdle(t *testing.T) {
+	cases := []struct {
+		description string
+		spans       []span
+		gaps        []interval
+	}{
+		{"no calls", nil, nil},
+		{
+			"calls following each other closely",
+			[]span{{Start: at(0), End: at(100)}, {Start: at(400), End: at(500)}},
+			nil,
+		},
+		{
+			"a long gap between two calls",
+			[]span{{Start: at(0), End: at(100)}, {Start: at(600), End: at(700)}},
+			[]interval{{Start: at(100), End: at(600)}},
+		},
+		{
+			"a long call covering the gap between shorter ones",
+			[]span{{Start: at(0), End: at(2000)}, {Start: at(100), End: at(200)}, {Start: at(1500), End: at(1600)}},
+			nil,
+		},
+		{
+			"unsorted calls with a gap after an overlap",
+			[]span{{Start: at(1500), End: at(1600)}, {Start: at(0), End: at(300)}, {Start: at(200), End: at(800)}},
+			[]interval{{Start: at(800), End: at(1500)}},
+		},
+	}
+
+	Convey("Given the spans of a run", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When they are "+c.description, func() {
+				gaps := idle(c.spans)
+
+				Convey("Then it should report the gaps with no call in flight", func() {
+					So(gaps, ShouldResemble, c.gaps)
+				})
+			})
+		}
+	})
+}
+
+func TestParallelism(t *testing.T) {
+	cases := []struct {
+		description string
+		spans       []span
+		parallel    int
+	}{
+		{"no calls", nil, 0},
+		{"a single call", []span{{Start: at(0), End: at(100)}}, 1},
+		{
+			"calls one after the other",
+			[]span{{Start: at(0), End: at(100)}, {Start: at(100), End: at(200)}},
+			1,
+		},
+		{
+			"three overlapping calls",
+			[]span{{Start: at(0), End: at(300)}, {Start: at(100), End: at(400)}, {Start: at(200), End: at(250)}},
+			3,
+		},
+		{
+			"two pairs of overlapping calls",
+			[]span{{Start: at(0), End: at(200)}, {Start: at(100), End: at(300)}, {Start: at(500), End: at(700)}, {Start: at(600), End: at(800)}},
+			2,
+		},
+	}
+
+	Convey("Given the spans of a batch", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When they are "+c.description, func() {
+				Convey("Then it should report the most calls in flight at once", func() {
+					So(parallelism(c.spans), ShouldEqual, c.parallel)
+				})
+			})
+		}
+	})
+}
@@ -0,0 +1,394 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// instanceGroup is the addressing part of an instance definition
+type instanceGroup struct {
+	Name    string
+	StartIP string
+	Count   int
+}
+
+type ipRange struct {
+	first uint32
+	last  uint32
+}
+
+// ipSubjects are the messages an ip ledger follows, the service ones
+// marking the end of each apply
+var ipSubjects = []string{"network.*.*", "instance.*.*", "service.*.done", "service.*.error"}
+
+// ipLedger follows the instances of a service across applies and checks
+// their addresses against the definition
+type ipLedger struct {
+	service  string
+	prefix   string
+	groups   []instanceGroup
+	ranges   map[string]ipRange
+	live     map[string]string
+	networks map[string]string
+	released map[string]string
+	reused   []string
+	tl       *timeline
+	seen     int
+}
+
+// newIPLedger creates a ledger for the instances of service on datacenter,
+// recording every network and instance message published from then on
+func newIPLedger(datacenter, service string) (*ipLedger, error) {
+	l := emptyIPLedger(datacenter, service)
+	tl, err := recordTimeline(ipSubjects...)
+	if err != nil {
+		return nil, err
+	}
+	l.tl = tl
+	return l, nil
+}
+
+func emptyIPLedger(datacenter, service string) *ipLedger {
+	return &ipLedger{
+		service:  service,
+		prefix:   datacenter + "-" + service + "-",
+		ranges:   make(map[string]ipRange),
+		live:     make(map[string]string),
+		networks: make(map[string]string),
+		released: make(map[string]string),
+	}
+}
+
+// Stop ends the recording
+func (l *ipLedger) Stop() {
+	l.tl.Stop()
+}
+
+// Groups sets the instance groups declared by the definition being applied
+func (l *ipLedger) Groups(groups ...instanceGroup) {
+	l.groups = groups
+}
+
+// Network declares the range of a network the service uses but does not
+// create
+func (l *ipLedger) Network(name, subnet string) error {
+	r, err := subnetRange(subnet)
+	if err != nil {
+		return err
+	}
+	l.ranges[name] = r
+	return nil
+}
+
+// Sync feeds the ledger the network and instance messages recorded up to
+// the end of the next apply of its service
+func (l *ipLedger) Sync() error {
+	timeout := time.After(time.Millisecond * 10000)
+	for {
+		entries := l.tl.Entries()
+		for i := l.seen; i < len(entries); i++ {
+			e := entries[i]
+			if strings.HasPrefix(e.Subject, "service.") {
+				if e.envelope().Name != l.service {
+					continue
+				}
+				for _, r := range entries[l.seen:i] {
+					l.record(r.Subject, r.Data)
+				}
+				l.seen = i + 1
+				return nil
+			}
+		}
+		select {
+		case <-timeout:
+			return errors.New("timeout waiting for " + l.service + " to be applied")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func (l *ipLedger) record(subject string, data []byte) {
+	parts := strings.Split(subject, ".")
+	if len(parts) != 3 {
+		return
+	}
+	aws := strings.HasPrefix(parts[2], "aws")
+
+	switch parts[0] {
+	case "network":
+		if parts[1] != "create" {
+			return
+		}
+		if aws {
+			e := awsNetworkEvent{}
+			json.Unmarshal(data, &e)
+			l.awsNetwork(e)
+		} else {
+			e := networkEvent{}
+			json.Unmarshal(data, &e)
+			l.network(e)
+		}
+	case "instance":
+		var name, network, ip string
+		if aws {
+			e := awsInstanceEvent{}
+			json.Unmarshal(data, &e)
+			name, network, ip = e.InstanceName, e.NetworkName, e.IP
+		} else {
+			e := instanceEvent{}
+			json.Unmarshal(data, &e)
+			name, network, ip = e.InstanceName, e.NetworkName, e.IP
+		}
+		if !strings.HasPrefix(name, l.prefix) {
+			return
+		}
+		switch parts[1] {
+		case "create":
+			l.created(name, network, ip)
+		case "update":
+			l.live[name] = ip
+			l.networks[name] = network
+		case "delete":
+			l.deleted(name)
+		}
+	}
+}
+
+func (l *ipLedger) network(e networkEvent) {
+	first, err1 := ipToInt(e.NetworkStartAddress)
+	last, err2 := ipToInt(e.NetworkEndAddress)
+	if err1 == nil && err2 == nil {
+		l.ranges[e.NetworkName] = ipRange{first: first, last: last}
+	}
+}
+
+func (l *ipLedger) awsNetwork(e awsNetworkEvent) {
+	if r, err := subnetRange(e.NetworkSubnet); err == nil {
+		l.ranges[e.NetworkName] = r
+	}
+}
+
+// subnetRange returns the host addresses of a subnet, leaving out its
+// network and broadcast addresses
+func subnetRange(subnet string) (ipRange, error) {
+	_, n, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return ipRange{}, err
+	}
+	if n.IP.To4() == nil {
+		return ipRange{}, fmt.Errorf("invalid ipv4 subnet %s", subnet)
+	}
+	first := binary.BigEndian.Uint32(n.IP.To4())
+	ones, bits := n.Mask.Size()
+	if bits-ones < 2 {
+		return ipRange{}, fmt.Errorf("%s has no host addresses", subnet)
+	}
+	return ipRange{first: first + 1, last: first + 1<<uint(bits-ones) - 2}, nil
+}
+
+func (l *ipLedger) created(name, network, ip string) {
+	if previous, ok := l.released[ip]; ok && previous != name {
+		l.reused = append(l.reused, fmt.Sprintf("%s reuses %s released by %s", name, ip, previous))
+	}
+	l.live[name] = ip
+	l.networks[name] = network
+}
+
+func (l *ipLedger) deleted(name string) {
+	if ip, ok := l.live[name]; ok {
+		l.released[ip] = name
+	}
+	delete(l.live, name)
+	delete(l.networks, name)
+}
+
+// Applied syncs the ledger with the apply of definition def and returns
+// every broken invariant of its instance groups
+func (l *ipLedger) Applied(def string) []string {
+	if err := l.Sync(); err != nil {
+		return []string{err.Error()}
+	}
+	groups, err := definitionGroups(def)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	l.Groups(groups...)
+	return l.Check()
+}
+
+// Check verifies every live instance against the current groups and
+// returns a description of each broken invariant
+func (l *ipLedger) Check() []string {
+	problems := append([]string(nil), l.reused...)
+
+	owners := make(map[string]string)
+	for _, name := range l.names() {
+		ip := l.live[name]
+		if owner, ok := owners[ip]; ok {
+			problems = append(problems, fmt.Sprintf("%s and %s share %s", owner, name, ip))
+		}
+		owners[ip] = name
+
+		r, ok := l.ranges[l.networks[name]]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is on %s whose range is unknown", name, l.networks[name]))
+			continue
+		}
+		if v, err := ipToInt(ip); err != nil || v < r.first || v > r.last {
+			problems = append(problems, fmt.Sprintf("%s has %s outside of %s range", name, ip, l.networks[name]))
+		}
+	}
+
+	for _, g := range l.groups {
+		problems = append(problems, l.checkGroup(g)...)
+	}
+
+	return problems
+}
+
+func (l *ipLedger) checkGroup(g instanceGroup) []string {
+	var problems []string
+
+	start, err := ipToInt(g.StartIP)
+	if err != nil {
+		return []string{fmt.Sprintf("%s has an invalid start_ip %s", g.Name, g.StartIP)}
+	}
+
+	members := make(map[int]string)
+	for _, name := range l.names() {
+		index, ok := l.index(g, name)
+		if !ok {
+			continue
+		}
+		members[index] = name
+		if index > g.Count {
+			problems = append(problems, fmt.Sprintf("%s exceeds %s count of %d", name, g.Name, g.Count))
+			continue
+		}
+		expected := intToIP(start + uint32(index-1))
+		if l.live[name] != expected {
+			problems = append(problems, fmt.Sprintf("%s expected to have %s but has %s", name, expected, l.live[name]))
+		}
+	}
+
+	for i := 1; i <= g.Count; i++ {
+		if _, ok := members[i]; !ok {
+			problems = append(problems, fmt.Sprintf("%s-%d is missing", g.Name, i))
+		}
+	}
+
+	return problems
+}
+
+func (l *ipLedger) index(g instanceGroup, name string) (int, bool) {
+	p := l.prefix + g.Name + "-"
+	if !strings.HasPrefix(name, p) {
+		return 0, false
+	}
+	i, err := strconv.Atoi(strings.TrimPrefix(name, p))
+	if err != nil || i < 1 {
+		return 0, false
+	}
+	return i, true
+}
+
+func (l *ipLedger) names() []string {
+	var names []string
+	for name := range l.live {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func ipToInt(ip string) (uint32, error) {
+	v := net.ParseIP(ip).To4()
+	if v == nil {
+		return 0, fmt.Errorf("invalid ipv4 address %s", ip)
+	}
+	return binary.BigEndian.Uint32(v), nil
+}
+
+func intToIP(v uint32) string {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, v)
+	return ip.String()
+}
+
+// definitionGroups reads the instance groups of a definition on the
+// definitions directory
+func definitionGroups(def string) ([]instanceGroup, error) {
+	_, filename, _, _ := runtime.Caller(0)
+	data, err := ioutil.ReadFile(path.Join(path.Dir(filename), "definitions", def))
+	if err != nil {
+		return nil, err
+	}
+	return parseGroups(string(data))
+}
+
+// parseGroups reads the name, start_ip and count of every entry of the top
+// level instances list of a definition
+func parseGroups(definition string) ([]instanceGroup, error) {
+	var groups []instanceGroup
+	var g *instanceGroup
+	inInstances := false
+
+	scanner := bufio.NewScanner(strings.NewReader(definition))
+	for scanner.Scan() {
+		line := scanner.Text()
+		t := strings.TrimSpace(line)
+		if t == "" || strings.HasPrefix(t, "#") {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '-' {
+			inInstances = t == "instances:"
+			continue
+		}
+		if !inInstances {
+			continue
+		}
+
+		item := strings.HasPrefix(t, "- ")
+		key, value := yamlField(strings.TrimPrefix(t, "- "))
+		switch {
+		case item && key == "name":
+			groups = append(groups, instanceGroup{Name: value})
+			g = &groups[len(groups)-1]
+		case g == nil:
+		case key == "start_ip":
+			g.StartIP = value
+		case key == "count":
+			count, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s has an invalid count %s", g.Name, value)
+			}
+			g.Count = count
+		}
+	}
+
+	return groups, scanner.Err()
+}
+
+// yamlField splits a key: value line, unquoting its value
+func yamlField(l string) (string, string) {
+	parts := strings.SplitN(l, ":", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return strings.TrimSpace(parts[0]), strings.Trim(strings.TrimSpace(parts[1]), `'"`)
+}
@@ -0,0 +1,178 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// ledgerEvent is a connector message fed to a ledger on the ledger tests
+type ledgerEvent struct {
+	subject string
+	event   interface{}
+}
+
+func vcloudInstance(action, name, network, ip string) ledgerEvent {
+	return ledgerEvent{"instance." + action + ".vcloud-fake", instanceEvent{InstanceName: name, NetworkName: network, IP: ip}}
+}
+
+func TestIPLedger(t *testing.T) {
+	web := networkEvent{NetworkName: "fake-s-web", NetworkStartAddress: "10.1.0.5", NetworkEndAddress: "10.1.0.250"}
+	webGroup := func(count int) []instanceGroup {
+		return []instanceGroup{{Name: "web", StartIP: "10.1.0.11", Count: count}}
+	}
+
+	cases := []struct {
+		description string
+		events      []ledgerEvent
+		groups      []instanceGroup
+		problems    []string
+	}{
+		{
+			"contiguous instances",
+			[]ledgerEvent{
+				{"network.create.vcloud-fake", web},
+				vcloudInstance("create", "fake-s-web-1", "fake-s-web", "10.1.0.11"),
+				vcloudInstance("create", "fake-s-web-2", "fake-s-web", "10.1.0.12"),
+			},
+			webGroup(2),
+			nil,
+		},
+		{
+			"a gap in the group",
+			[]ledgerEvent{
+				{"network.create.vcloud-fake", web},
+				vcloudInstance("create", "fake-s-web-1", "fake-s-web", "10.1.0.11"),
+				vcloudInstance("create", "fake-s-web-2", "fake-s-web", "10.1.0.13"),
+			},
+			webGroup(2),
+			[]string{"fake-s-web-2 expected to have 10.1.0.12 but has 10.1.0.13"},
+		},
+		{
+			"a missing and a duplicated address",
+			[]ledgerEvent{
+				{"network.create.vcloud-fake", web},
+				vcloudInstance("create", "fake-s-web-1", "fake-s-web", "10.1.0.11"),
+				vcloudInstance("create", "fake-s-web-3", "fake-s-web", "10.1.0.11"),
+			},
+			webGroup(2),
+			[]string{
+				"fake-s-web-1 and fake-s-web-3 share 10.1.0.11",
+				"fake-s-web-3 exceeds web count of 2",
+				"web-2 is missing",
+			},
+		},
+		{
+			"an address outside of the network range",
+			[]ledgerEvent{
+				{"network.create.vcloud-fake", web},
+				vcloudInstance("create", "fake-s-web-1", "fake-s-web", "10.1.0.251"),
+			},
+			[]instanceGroup{{Name: "web", StartIP: "10.1.0.251", Count: 1}},
+			[]string{"fake-s-web-1 has 10.1.0.251 outside of fake-s-web range"},
+		},
+		{
+			"a network whose range is unknown",
+			[]ledgerEvent{
+				vcloudInstance("create", "fake-s-web-1", "fake-s-web", "10.1.0.11"),
+			},
+			webGroup(1),
+			[]string{"fake-s-web-1 is on fake-s-web whose range is unknown"},
+		},
+		{
+			"a scale down then up reusing the released address",
+			[]ledgerEvent{
+				{"network.create.vcloud-fake", web},
+				vcloudInstance("create", "fake-s-web-1", "fake-s-web", "10.1.0.11"),
+				vcloudInstance("create", "fake-s-web-2", "fake-s-web", "10.1.0.12"),
+				vcloudInstance("delete", "fake-s-web-2", "fake-s-web", "10.1.0.12"),
+				vcloudInstance("create", "fake-s-web-2", "fake-s-web", "10.1.0.12"),
+			},
+			webGroup(2),
+			nil,
+		},
+		{
+			"a released address taken by another instance",
+			[]ledgerEvent{
+				{"network.create.vcloud-fake", web},
+				vcloudInstance("create", "fake-s-web-1", "fake-s-web", "10.1.0.11"),
+				vcloudInstance("create", "fake-s-web-2", "fake-s-web", "10.1.0.12"),
+				vcloudInstance("delete", "fake-s-web-2", "fake-s-web", "10.1.0.12"),
+				vcloudInstance("create", "fake-s-db-1", "fake-s-web", "10.1.0.12"),
+			},
+			webGroup(1),
+			[]string{"fake-s-db-1 reuses 10.1.0.12 released by fake-s-web-2"},
+		},
+		{
+			"instances of other services",
+			[]ledgerEvent{
+				{"network.create.vcloud-fake", web},
+				vcloudInstance("create", "fake-s-web-1", "fake-s-web", "10.1.0.11"),
+				vcloudInstance("create", "fake-other-web-1", "fake-other-web", "10.1.0.11"),
+			},
+			webGroup(1),
+			nil,
+		},
+		{
+			"aws subnets leaving out the network and broadcast addresses",
+			[]ledgerEvent{
+				{"network.create.aws-fake", awsNetworkEvent{NetworkName: "fakeaws-s-web", NetworkSubnet: "10.1.0.0/24"}},
+				{"instance.create.aws-fake", awsInstanceEvent{InstanceName: "fake-s-web-1", NetworkName: "fakeaws-s-web", IP: "10.1.0.0"}},
+				{"instance.create.aws-fake", awsInstanceEvent{InstanceName: "fake-s-web-2", NetworkName: "fakeaws-s-web", IP: "10.1.0.255"}},
+			},
+			nil,
+			[]string{
+				"fake-s-web-1 has 10.1.0.0 outside of fakeaws-s-web range",
+				"fake-s-web-2 has 10.1.0.255 outside of fakeaws-s-web range",
+			},
+		},
+	}
+
+	Convey("Given an ip ledger", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When it records "+c.description, func() {
+				l := emptyIPLedger("fake", "s")
+				for _, e := range c.events {
+					data, _ := json.Marshal(e.event)
+					l.record(e.subject, data)
+				}
+				l.Groups(c.groups...)
+
+				Convey("Then it should report the broken invariants", func() {
+					So(l.Check(), ShouldResemble, c.problems)
+				})
+			})
+		}
+	})
+}
+
+func TestDefinitionGroups(t *testing.T) {
+	cases := []struct {
+		definition string
+		groups     []instanceGroup
+	}{
+		{"novse9.yml", []instanceGroup{{"web", "10.1.0.11", 2}, {"db", "10.2.0.11", 1}}},
+		{"inst3.yml", []instanceGroup{{"stg", "10.2.0.90", 2}, {"dev", "10.1.0.90", 1}}},
+		{"aws10.yml", []instanceGroup{{"web", "10.1.0.11", 1}, {"bknd", "10.2.0.11", 1}}},
+	}
+
+	Convey("Given the definitions", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When I read the instance groups of "+c.definition, func() {
+				groups, err := definitionGroups(c.definition)
+
+				Convey("Then I should get every group with its start ip and count", func() {
+					So(err, ShouldBeNil)
+					So(groups, ShouldResemble, c.groups)
+				})
+			})
+		}
+	})
+}
@@ -0,0 +1,112 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// connectorSubjects are the subjects allowed to carry credentials, the
+// connector calls and their replies, and the replies to the harness own
+// config and store requests
+var connectorSubjects = []string{
+	"*.*.vcloud-fake",
+	"*.*.vcloud-fake.>",
+	"*.*.aws-fake",
+	"*.*.aws-fake.>",
+	"*.*.fake",
+	"*.*.fake.>",
+	harnessInbox + ">",
+}
+
+// leak is a known secret found on a message outside the connector subjects
+type leak struct {
+	Subject string
+	Secret  string
+	Field   string
+}
+
+func (l leak) String() string {
+	return fmt.Sprintf("%s leaked on %s at %s", l.Secret, l.Subject, l.Field)
+}
+
+// Leaks scans every recorded message not matching allowed for the known
+// secrets and for the masks of a redacted recording, reporting each of them
+// by name and never by value
+func (t *timeline) Leaks(allowed []string) []leak {
+	var leaks []leak
+
+	values := knownSecrets()
+	patterns := make([]*regexp.Regexp, len(values))
+	for i, s := range values {
+		patterns[i] = secretPattern(s)
+	}
+
+	for _, e := range t.Entries() {
+		if matchesAny(allowed, e.Subject) {
+			continue
+		}
+
+		var data interface{}
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			data = string(e.Data)
+		}
+
+		walkStrings(data, "$", func(field, value string) {
+			for i, re := range patterns {
+				if re.MatchString(unmasked(value)) {
+					leaks = append(leaks, leak{Subject: e.Subject, Secret: secretName(values[i]), Field: field})
+				}
+			}
+			for _, m := range redactedPattern.FindAllStringSubmatch(value, -1) {
+				name := strings.TrimSpace(m[1])
+				if name == "" {
+					name = "secret field"
+				}
+				leaks = append(leaks, leak{Subject: e.Subject, Secret: name, Field: field})
+			}
+		})
+	}
+
+	sort.SliceStable(leaks, func(i, j int) bool {
+		if leaks[i].Subject != leaks[j].Subject {
+			return leaks[i].Subject < leaks[j].Subject
+		}
+		return leaks[i].Field < leaks[j].Field
+	})
+
+	return leaks
+}
+
+func matchesAny(patterns []string, subject string) bool {
+	for _, p := range patterns {
+		if subjectMatches(p, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkStrings calls fn with the path of every string value of a decoded
+// json document, field names are never reported as values
+func walkStrings(v interface{}, field string, fn func(field, value string)) {
+	switch x := v.(type) {
+	case string:
+		fn(field, x)
+	case map[string]interface{}:
+		for k, child := range x {
+			walkStrings(child, field+"."+k, fn)
+		}
+	case []interface{}:
+		for i, child := range x {
+			walkStrings(child, field+"["+strconv.Itoa(i)+"]", fn)
+		}
+	}
+}
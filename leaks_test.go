@@ -0,0 +1,63 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCredentialLeaks(t *testing.T) {
+	var suffix = strconv.Itoa(rand.Intn(9999999))
+
+	owner := newTenant("leak" + suffix)
+
+	basicSetup("vcloud")
+
+	Convey("Given I record every message published on nats", t, func() {
+		tl, err := recordTimeline(">")
+		So(err, ShouldBeNil)
+
+		Convey("When I run vcloud, salt and aws services with several users", func() {
+			owner.setup()
+			owner.ernest("service", "apply", getDefinitionPathOn("inst1.yml", "leak"+suffix, owner.Datacenter))
+
+			awsDatacenter, awsSecret := owner.Datacenter+"_aws", owner.Password+"_aws"
+			registerSecret(owner.User+" aws secret", awsSecret)
+			owner.ernest("datacenter", "create", "aws", awsDatacenter, "--region", "fake", "--token", "fake", "--secret", awsSecret, "--fake")
+			owner.ernest("service", "apply", getDefinitionPathOn("aws1.yml", "leakaws"+suffix, awsDatacenter))
+			owner.ernest("service", "destroy", "--force", "leakaws"+suffix)
+
+			login()
+			ernest("service", "apply", getDefinitionPath("novse12.yml", "leaksalt"+suffix))
+			tl.Stop()
+
+			Convey("Then no secret should be published outside the connector subjects", func() {
+				So(len(tl.Entries()), ShouldBeGreaterThan, 0)
+
+				leaks := tl.Leaks(connectorSubjects)
+				for _, l := range leaks {
+					Printf("\n%s", l.String())
+				}
+				So(len(leaks), ShouldEqual, 0)
+
+				Info("And once redacted no secret value should be left on any subject", " ", 8)
+				var left []string
+				for _, e := range tl.Entries() {
+					data := unmasked(string(redactJSON(e.Data)))
+					for _, v := range knownSecrets() {
+						if secretPattern(v).MatchString(data) {
+							left = append(left, secretName(v)+" on "+e.Subject)
+						}
+					}
+				}
+				So(left, ShouldBeEmpty)
+			})
+		})
+	})
+}
@@ -0,0 +1,233 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const usage = `usage: uat-agent <command> [arguments]
+
+commands:
+  timeline [--format html|mermaid] [--output file] <run>
+      renders a gantt chart of the connector calls of a recorded run
+  audit [--secret name=value]... <run>
+      reports the secrets published outside the connector subjects of a
+      recorded run, the harness default credentials are always checked
+  run [--service name] [--tags a,b] [--skip-tags c,d] [--resume-from step]
+      <manifest|dir>...
+      runs the scenarios of the given manifests against the stack on
+      NATS_URI, reporting the unmet expectations of every step. Only the
+      steps tagged with any of tags and none of skip-tags are run. With
+      resume-from, the scenarios holding that step run from it on, reusing
+      the service checkpointed last, or the given one, when it still holds
+      the state the step starts from, or replaying the apply before it on a
+      new service first
+  catalog [--template file] [<manifest|dir>...]
+      prints the commands catalog with the steps of the scenarios filled in
+      as the commands a tester would run, on the block following their
+      <!-- scenario: name --> marker of the template. The template defaults
+      to definitions/commands.md and the scenarios directory is read by
+      default
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "timeline":
+		err = timelineCmd(os.Args[2:])
+	case "audit":
+		err = auditCmd(os.Args[2:])
+	case "run":
+		err = runCmd(os.Args[2:])
+	case "catalog":
+		err = catalogCmd(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+func timelineCmd(args []string) error {
+	fs := flag.NewFlagSet("timeline", flag.ExitOnError)
+	format := fs.String("format", "html", "output format, html or mermaid")
+	output := fs.String("output", "", "file to write the chart to, defaults to stdout")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("a recorded run is required")
+	}
+
+	t, err := loadRun(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *output != "" {
+		w, err = os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+
+	title := path.Base(fs.Arg(0))
+	switch *format {
+	case "html":
+		renderHTML(w, title, t.Spans())
+	case "mermaid":
+		renderMermaid(w, title, t.Spans())
+	default:
+		return fmt.Errorf("unknown format %s", *format)
+	}
+
+	return nil
+}
+
+// secretFlags collects repeated name=value secret flags
+type secretFlags []string
+
+func (s *secretFlags) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *secretFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return fmt.Errorf("secrets must be given as name=value")
+	}
+	*s = append(*s, value)
+	registerSecret(parts[0], parts[1])
+	return nil
+}
+
+func auditCmd(args []string) error {
+	var extra secretFlags
+
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	fs.Var(&extra, "secret", "additional secret to look for, as name=value")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("a recorded run is required")
+	}
+
+	t, err := loadRun(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	registerDefaultSecrets()
+
+	leaks := t.Leaks(connectorSubjects)
+	for _, l := range leaks {
+		fmt.Println(l.String())
+	}
+	if len(leaks) > 0 {
+		return fmt.Errorf("%d secrets leaked", len(leaks))
+	}
+
+	return nil
+}
+
+func runCmd(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	service := fs.String("service", "", "service name, defaults to the scenario name and a random suffix")
+	tags := fs.String("tags", "", "comma separated tags of the steps to run")
+	skip := fs.String("skip-tags", "", "comma separated tags of the steps to skip")
+	from := fs.String("resume-from", "", "definition or step to resume the scenarios from")
+	fs.Parse(args)
+
+	filter := tagFilter{Tags: parseTags(*tags), Skip: parseTags(*skip)}
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("a scenario manifest is required")
+	}
+
+	scenarios, err := loadScenarios(fs.Args()...)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, s := range scenarios {
+		if !s.selected(filter) || (*from != "" && !s.hasStep(*from)) {
+			continue
+		}
+		name := *service
+		opts := runOptions{}
+		if *from != "" {
+			if opts, name, err = s.resume(*from, name); err != nil {
+				return err
+			}
+		}
+		if name == "" {
+			name = strings.Replace(s.Name, "-", "", -1) + strconv.Itoa(rand.Intn(9999999))
+		}
+		opts.Filter = filter
+
+		fmt.Printf("%s (%s)\n", s.Name, name)
+		s.run(name, opts, func(step scenarioStep, errs []string) {
+			if len(errs) == 0 {
+				fmt.Printf("  ok    %s\n", step.Name())
+				if driver == httpDriver && step.Expect.checksOutput() {
+					fmt.Printf("  skip  %s output, checked with the cli driver only\n", step.Name())
+				}
+				return
+			}
+			failed++
+			fmt.Printf("  fail  %s\n", step.Name())
+			for _, e := range errs {
+				fmt.Printf("        %s\n", e)
+			}
+		})
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d steps failed", failed)
+	}
+
+	return nil
+}
+
+func catalogCmd(args []string) error {
+	fs := flag.NewFlagSet("catalog", flag.ExitOnError)
+	template := fs.String("template", catalogPath(), "catalog holding the scenario markers")
+	fs.Parse(args)
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{scenariosPath()}
+	}
+
+	scenarios, err := loadScenarios(paths...)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(*template)
+	if err != nil {
+		return err
+	}
+
+	return renderCatalog(os.Stdout, string(data), scenarios)
+}
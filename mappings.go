@@ -169,11 +169,14 @@ type awsInstanceEvent struct {
 	DatacenterAccessToken string   `json:"datacenter_token,omitempty"`
 	DatacenterAccessKey   string   `json:"datacenter_secret,omitempty"`
 	DatacenterVpcID       string   `json:"vpc_id,omitempty"`
+	NetworkName           string   `json:"network_name"`
 	NetworkAWSID          string   `json:"network_aws_id"`
 	SecurityGroupAWSIDs   []string `json:"security_group_aws_ids"`
 	InstanceName          string   `json:"name"`
+	InstanceAWSID         string   `json:"instance_aws_id"`
 	InstanceImage         string   `json:"image"`
 	InstanceType          string   `json:"instance_type"`
+	IP                    string   `json:"ip"`
 	Status                string   `json:"status"`
 	ErrorCode             string   `json:"error_code"`
 	ErrorMessage          string   `json:"error_message"`
@@ -188,6 +191,7 @@ type awsNetworkEvent struct {
 	DatacenterAccessToken string `json:"datacenter_token,omitempty"`
 	DatacenterAccessKey   string `json:"datacenter_secret,omitempty"`
 	DatacenterVpcID       string `json:"vpc_id,omitempty"`
+	NetworkName           string `json:"name"`
 	NetworkType           string `json:"network_type"`
 	NetworkSubnet         string `json:"range"`
 	NetworkAWSID          string `json:"network_aws_id"`
@@ -210,6 +214,7 @@ type awsFirewallEvent struct {
 	DatacenterAccessKey   string `json:"datacenter_secret"`
 	DatacenterVPCID       string `json:"vpc_id"`
 	SecurityGroupName     string `json:"name"`
+	SecurityGroupAWSID    string `json:"security_group_aws_id"`
 	SecurityGroupRules    struct {
 		Ingress []awsFirewallRule `json:"ingress"`
 		Egress  []awsFirewallRule `json:"egress"`
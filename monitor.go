@@ -0,0 +1,271 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats"
+)
+
+// sseEvent is a message received on a monitor event stream
+type sseEvent struct {
+	ID       string
+	Event    string
+	Data     []byte
+	Received time.Time
+}
+
+// Subject returns the nats subject a monitor message was built from
+func (e sseEvent) Subject() string {
+	var m struct {
+		Subject string `json:"_subject"`
+	}
+	json.Unmarshal(e.Data, &m)
+	return m.Subject
+}
+
+// monitorSubjects are the nats subjects monit shows on a service stream,
+// the connector replies and the service ones, which have a token less than
+// the connector replies so replySubjects never match them
+var monitorSubjects = []string{"*.*.*.done", "*.*.*.error", "service.*.done", "service.*.error"}
+
+// monitorStream reads the /events stream of a service, as the cli does
+// while applying
+type monitorStream struct {
+	sync.Mutex
+	events  []sseEvent
+	resp    *http.Response
+	err     error
+	stopped bool
+	opened  chan struct{}
+}
+
+// watchNext subscribes to the monitor stream of the next service named name
+// with the jwt of the api driver session of usr, and must be called before
+// applying it. Monit creates the stream once the service is created, so it
+// is opened then asking for the messages already published to be replayed
+func watchNext(usr, name string) (*monitorStream, error) {
+	s := session(usr)
+	if s.Token == "" {
+		return nil, errors.New("the api driver is not logged in as " + usr)
+	}
+
+	creates := make(chan *nats.Msg, 10)
+	sub, err := n.ChanSubscribe("service.create", creates)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &monitorStream{opened: make(chan struct{})}
+	go func() {
+		defer close(m.opened)
+		defer sub.Unsubscribe()
+
+		timeout := time.After(time.Millisecond * 10000)
+		for {
+			select {
+			case msg := <-creates:
+				var created storedService
+				json.Unmarshal(msg.Data, &created)
+				if created.Name != name {
+					continue
+				}
+				m.open(s, created.ID)
+				return
+			case <-timeout:
+				m.fail(errors.New("timeout waiting for " + name + " to be created"))
+				return
+			}
+		}
+	}()
+
+	return m, nil
+}
+
+// open connects to the stream of a service, retrying until monit has
+// created it
+func (m *monitorStream) open(s *apiSession, id string) {
+	timeout := time.After(time.Millisecond * 10000)
+	for {
+		resp, err := m.connect(s, id)
+		if err == nil {
+			m.Lock()
+			m.resp = resp
+			m.Unlock()
+			go m.read()
+			return
+		}
+
+		select {
+		case <-timeout:
+			m.fail(err)
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (m *monitorStream) connect(s *apiSession, id string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", s.endpoint("/events?stream="+url.QueryEscape(id)), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+s.Token)
+	req.Header.Set("Last-Event-ID", "0")
+
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("monitor stream for %s: %s", id, resp.Status)
+	}
+
+	return resp, nil
+}
+
+// fail records the first error reading the stream, errors caused by
+// stopping it being ignored
+func (m *monitorStream) fail(err error) {
+	m.Lock()
+	defer m.Unlock()
+	if err != nil && m.err == nil && !m.stopped {
+		m.err = err
+	}
+}
+
+func (m *monitorStream) failed() error {
+	m.Lock()
+	defer m.Unlock()
+	return m.err
+}
+
+func (m *monitorStream) read() {
+	scanner := bufio.NewScanner(m.resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	e := sseEvent{}
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "":
+			if data.Len() > 0 {
+				e.Data = bytes.TrimSuffix(data.Bytes(), []byte("\n"))
+				e.Received = time.Now()
+				m.Lock()
+				m.events = append(m.events, e)
+				m.Unlock()
+			}
+			e = sseEvent{}
+			data = bytes.Buffer{}
+		case "id":
+			e.ID = value
+		case "event":
+			e.Event = value
+		case "data":
+			data.WriteString(value + "\n")
+		}
+	}
+
+	m.fail(scanner.Err())
+}
+
+// Stop closes the stream, returning the error it failed with if any
+func (m *monitorStream) Stop() error {
+	<-m.opened
+
+	m.Lock()
+	defer m.Unlock()
+	m.stopped = true
+	if m.resp != nil {
+		m.resp.Body.Close()
+	}
+	return m.err
+}
+
+// Events returns every message received in arrival order
+func (m *monitorStream) Events() []sseEvent {
+	m.Lock()
+	defer m.Unlock()
+	return append([]sseEvent{}, m.events...)
+}
+
+// Wait blocks until a message built from a subject matching pattern has
+// been received, or the stream fails
+func (m *monitorStream) Wait(pattern string) error {
+	timeout := time.After(time.Millisecond * 10000)
+	for {
+		for _, e := range m.Events() {
+			if subjectMatches(pattern, e.Subject()) {
+				return nil
+			}
+		}
+		if err := m.failed(); err != nil {
+			return err
+		}
+		select {
+		case <-timeout:
+			return errors.New("timeout")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// Follows checks the monitor messages were received in the order their
+// subjects were published on nats, returning the ones out of order. Every
+// occurrence of a subject is paired with the publication of the same rank,
+// so a message is checked against the last one shown before it whatever its
+// subject
+func (m *monitorStream) Follows(t *timeline) []string {
+	published := make(map[string][]int)
+	for i, e := range t.Entries() {
+		published[e.Subject] = append(published[e.Subject], i)
+	}
+
+	var errs []string
+	shown := make(map[string]int)
+	last, lastSubject := -1, ""
+	for _, e := range m.Events() {
+		subject := e.Subject()
+		if subject == "" {
+			continue
+		}
+		rank := shown[subject]
+		shown[subject]++
+		if len(published[subject]) == 0 {
+			errs = append(errs, subject+" was never published on nats")
+			continue
+		}
+		if rank >= len(published[subject]) {
+			errs = append(errs, fmt.Sprintf("%s was shown %d times but published %d", subject, rank+1, len(published[subject])))
+			continue
+		}
+		i := published[subject][rank]
+		if i < last {
+			errs = append(errs, subject+" was shown after "+lastSubject)
+		}
+		last, lastSubject = i, subject
+	}
+
+	return errs
+}
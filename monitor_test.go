@@ -0,0 +1,181 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// applyWatching applies a definition of service while reading its monitor
+// stream, subscribed to before applying
+func applyWatching(f, service string) (*monitorStream, string, error) {
+	stream, err := watchNext("", service)
+	if err != nil {
+		return nil, "", err
+	}
+
+	o, _ := ernest("service", "apply", f)
+	return stream, o, nil
+}
+
+// containsName reports if a monitor message refers to a resource name
+func containsName(data []byte, name string) bool {
+	var m map[string]interface{}
+	json.Unmarshal(data, &m)
+	found := false
+	walkStrings(m, "$", func(field, value string) {
+		if value == name {
+			found = true
+		}
+	})
+	return found
+}
+
+func TestMonitorStream(t *testing.T) {
+	var suffix = strconv.Itoa(rand.Intn(9999999))
+
+	basicSetup("vcloud")
+	ernestVia(httpDriver, "", "target", ernest_instance)
+	ernestVia(httpDriver, "", "login", "--user", default_usr, "--password", default_pwd)
+
+	Convey("Given I watch the monitor stream of the services I apply", t, func() {
+		Convey("When I apply a valid novse12.yml definition", func() {
+			tl, err := recordTimeline(monitorSubjects...)
+			So(err, ShouldBeNil)
+			stream, _, err := applyWatching(getDefinitionPath("novse12.yml", "mon"+suffix), "mon"+suffix)
+			So(err, ShouldBeNil)
+			completed := stream.Wait("service.create.done")
+			tl.Stop()
+			streamErr := stream.Stop()
+
+			Convey("Then I should see the progress of every created resource", func() {
+				So(completed, ShouldBeNil)
+				So(streamErr, ShouldBeNil)
+
+				events := stream.Events()
+				So(len(events), ShouldBeGreaterThan, 0)
+
+				for _, e := range tl.Matching("*.create.*.done") {
+					env := e.envelope()
+					if env.Name == "" {
+						continue
+					}
+					shown := false
+					for _, m := range events {
+						if m.Subject() == e.Subject && json.Valid(m.Data) && containsName(m.Data, env.Name) {
+							shown = true
+						}
+					}
+					Info("And "+env.Name+" should be shown as created", " ", 8)
+					So(shown, ShouldBeTrue)
+				}
+
+				Info("And in the order they were published", " ", 8)
+				So(stream.Follows(tl), ShouldBeEmpty)
+
+				Info("And the service should be shown as completed last", " ", 8)
+				So(events[len(events)-1].Subject(), ShouldEqual, "service.create.done")
+			})
+		})
+
+		// failing an execution needs the harness to be the only one
+		// answering them
+		whenFailing := Convey
+		if !executionsByHarness {
+			whenFailing = SkipConvey
+		}
+		whenFailing("When I apply a definition whose execution fails", func() {
+			responder, err := respondExecutions("monerr"+suffix, map[string]fakeExecution{
+				"Execution web 1": {Reports: map[string]report{
+					"fake-monerr" + suffix + "-web-1": {Code: 1, StdErr: "date: invalid date"},
+				}},
+			}, nil)
+			So(err, ShouldBeNil)
+			tl, err := recordTimeline(monitorSubjects...)
+			So(err, ShouldBeNil)
+			stream, _, err := applyWatching(getDefinitionPath("novse12.yml", "monerr"+suffix), "monerr"+suffix)
+			So(err, ShouldBeNil)
+			failed := stream.Wait("service.create.error")
+			responder.Stop()
+			tl.Stop()
+			streamErr := stream.Stop()
+
+			Convey("Then I should see the error and the failed service", func() {
+				So(failed, ShouldBeNil)
+				So(streamErr, ShouldBeNil)
+
+				errored := false
+				for _, m := range stream.Events() {
+					if m.Subject() == "execution.create.fake.error" {
+						errored = true
+					}
+				}
+				So(errored, ShouldBeTrue)
+				So(stream.Follows(tl), ShouldBeEmpty)
+
+				events := stream.Events()
+				So(len(events), ShouldBeGreaterThan, 0)
+				So(events[len(events)-1].Subject(), ShouldEqual, "service.create.error")
+			})
+		})
+	})
+}
+
+func TestStreamFollows(t *testing.T) {
+	published := recorded("network.create.vcloud-fake.done", "instance.create.vcloud-fake.done", "instance.create.vcloud-fake.done", "service.create.done")
+
+	shown := func(subjects ...string) *monitorStream {
+		m := &monitorStream{}
+		for _, s := range subjects {
+			m.events = append(m.events, sseEvent{Data: []byte(`{"_subject":"` + s + `"}`)})
+		}
+		return m
+	}
+
+	cases := []struct {
+		description string
+		stream      *monitorStream
+		errs        []string
+	}{
+		{
+			"every message in order",
+			shown("network.create.vcloud-fake.done", "instance.create.vcloud-fake.done", "instance.create.vcloud-fake.done", "service.create.done"),
+			nil,
+		},
+		{
+			"the last message of a subject after the next subject",
+			shown("network.create.vcloud-fake.done", "instance.create.vcloud-fake.done", "service.create.done", "instance.create.vcloud-fake.done"),
+			[]string{"instance.create.vcloud-fake.done was shown after service.create.done"},
+		},
+		{
+			"a message shown more times than published",
+			shown("network.create.vcloud-fake.done", "network.create.vcloud-fake.done"),
+			[]string{"network.create.vcloud-fake.done was shown 2 times but published 1"},
+		},
+		{
+			"a message never published",
+			shown("router.create.vcloud-fake.done"),
+			[]string{"router.create.vcloud-fake.done was never published on nats"},
+		},
+	}
+
+	Convey("Given the messages published on nats during an apply", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When the monitor stream shows "+c.description, func() {
+				errs := c.stream.Follows(published)
+
+				Convey("Then every message shown out of order should be reported", func() {
+					So(errs, ShouldResemble, c.errs)
+				})
+			})
+		}
+	})
+}
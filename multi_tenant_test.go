@@ -0,0 +1,200 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMultiTenantIsolation(t *testing.T) {
+	var suffix = strconv.Itoa(rand.Intn(9999999))
+
+	tenants := []tenant{newTenant("tena" + suffix), newTenant("tenb" + suffix)}
+	services := make(map[string]string)
+
+	inCreateSub := make(chan *nats.Msg, 1)
+	deleteSub := make(chan *nats.Msg, 1)
+
+	basicSetup("vcloud")
+	for _, tn := range tenants {
+		tn.setup()
+		services[tn.Group] = "iso" + tn.Group
+	}
+
+	Convey("Given I have two tenants with their own datacenters", t, func() {
+		for i, owner := range tenants {
+			owner := owner
+			other := tenants[(i+1)%len(tenants)]
+			service := services[owner.Group]
+
+			Convey("When "+owner.User+" applies inst1.yml on its datacenter", func() {
+				sub, _ := n.ChanSubscribe("instance.create.vcloud-fake", inCreateSub)
+				f := getDefinitionPathOn("inst1.yml", service, owner.Datacenter)
+
+				_, err := owner.ernest("service", "apply", f)
+				Convey("Then the connectors should only get its own credentials", func() {
+					if err != nil {
+						log.Println(err.Error())
+					}
+
+					event := instanceEvent{}
+					msg, err := waitMsg(inCreateSub)
+					So(err, ShouldBeNil)
+					json.Unmarshal(msg.Data, &event)
+
+					So(event.InstanceName, ShouldEqual, owner.Datacenter+"-"+service+"-stg-1")
+					So(event.DatacenterName, ShouldEqual, owner.Datacenter)
+					So(event.DatacenterUsername, ShouldEqual, owner.User+"@"+owner.Org)
+					So(event.DatacenterPassword, ShouldEqualSecret, owner.Password)
+					So(string(msg.Data), ShouldNotContainSecret, other.Password)
+					So(string(msg.Data), ShouldNotContainSubstring, other.Org)
+				})
+
+				sub.Unsubscribe()
+			})
+
+			Convey("When "+owner.User+" lists and reads its own service", func() {
+				list, _ := owner.ernest("service", "list")
+				datacenters, _ := owner.ernest("datacenter", "list")
+				definition, _ := owner.ernest("service", "definition", service)
+				info, _ := owner.ernest("service", "info", service)
+				history, _ := owner.ernest("service", "history", service)
+
+				Convey("Then it should see its service and datacenter", func() {
+					_, listed := findServiceRecord(parseServiceList(list), service)
+					So(listed, ShouldBeTrue)
+					_, listed = findDatacenterRecord(parseDatacenterList(datacenters), owner.Datacenter)
+					So(listed, ShouldBeTrue)
+
+					Info("And it should get its definition and details", " ", 8)
+					So(definition, ShouldContainSubstring, "start_ip: 10.2.0.90")
+					So(info, ShouldContainSubstring, "10.2.0.90")
+
+					Info("And it should see the build it applied", " ", 8)
+					builds := parseServiceHistory(history)
+					So(len(builds), ShouldBeGreaterThan, 0)
+					So(builds[0].Name, ShouldEqual, service)
+					So(builds[0].Status, ShouldEqual, "done")
+				})
+			})
+
+			Convey("When the admin lists the users and groups", func() {
+				users, _ := ernestAs(admin_usr, "user", "list")
+				groups, _ := ernestAs(admin_usr, "group", "list")
+
+				Convey("Then "+owner.User+" should be listed on "+owner.Group, func() {
+					g, listed := findGroupRecord(parseGroupList(groups), owner.Group)
+					So(listed, ShouldBeTrue)
+					u, listed := findUserRecord(parseUserList(users), owner.User)
+					So(listed, ShouldBeTrue)
+
+					Info("And its group should be shown by id or name", " ", 8)
+					So([]string{g.ID, g.Name}, ShouldContain, u.Group)
+				})
+			})
+
+			Convey("When "+other.User+" lists services and datacenters", func() {
+				list, _ := other.ernest("service", "list")
+				datacenters, _ := other.ernest("datacenter", "list")
+
+				Convey("Then it should not see the ones of "+owner.Group, func() {
+					_, listed := findServiceRecord(parseServiceList(list), service)
+					So(listed, ShouldBeFalse)
+					_, listed = findDatacenterRecord(parseDatacenterList(datacenters), owner.Datacenter)
+					So(listed, ShouldBeFalse)
+
+					Info("And it should see its own datacenter", " ", 8)
+					_, listed = findDatacenterRecord(parseDatacenterList(datacenters), other.Datacenter)
+					So(listed, ShouldBeTrue)
+				})
+			})
+
+			Convey("When "+other.User+" reads the service of "+owner.Group, func() {
+				definition, _ := other.ernest("service", "definition", service)
+				info, _ := other.ernest("service", "info", service)
+
+				Convey("Then it should not get its definition or details", func() {
+					So(definition, ShouldContainSubstring, serviceNotFound)
+					So(definition, ShouldNotContainSubstring, "start_ip")
+					So(info, ShouldContainSubstring, serviceNotFound)
+					So(info, ShouldNotContainSubstring, "10.2.0.90")
+				})
+			})
+
+			Convey("When "+other.User+" applies to the service of "+owner.Group+" on its own datacenter", func() {
+				sub, _ := n.ChanSubscribe("instance.create.vcloud-fake", inCreateSub)
+				f := getDefinitionPathOn("inst2.yml", service, other.Datacenter)
+
+				o, _ := other.ernest("service", "apply", f)
+				Convey("Then it should be rejected", func() {
+					Info("And the cli should say the service belongs to another group", " ", 8)
+					So(o, ShouldContainSubstring, serviceNameTaken)
+
+					_, err := waitMsgTime(inCreateSub, 2*time.Second)
+					So(err, ShouldNotBeNil)
+
+					Info("And the service of "+owner.Group+" should be left on its datacenter", " ", 8)
+					builds, err := findServices(service)
+					So(err, ShouldBeNil)
+					So(len(builds), ShouldEqual, 1)
+					d, err := getDatacenter(owner.Datacenter)
+					So(err, ShouldBeNil)
+					So(builds[0].DatacenterID, ShouldEqual, d.ID)
+				})
+
+				sub.Unsubscribe()
+			})
+
+			Convey("When "+other.User+" applies to the service of "+owner.Group+" on its datacenter", func() {
+				sub, _ := n.ChanSubscribe("instance.create.vcloud-fake", inCreateSub)
+				f := getDefinitionPathOn("inst2.yml", service, owner.Datacenter)
+
+				o, _ := other.ernest("service", "apply", f)
+				Convey("Then it should be rejected", func() {
+					Info("And the cli should say the datacenter does not exist", " ", 8)
+					So(o, ShouldContainSubstring, datacenterNotFound)
+
+					_, err := waitMsgTime(inCreateSub, 2*time.Second)
+					So(err, ShouldNotBeNil)
+
+					Info("And the service of "+owner.Group+" should be left as it was", " ", 8)
+					builds, err := findServices(service)
+					So(err, ShouldBeNil)
+					So(len(builds), ShouldEqual, 1)
+				})
+
+				sub.Unsubscribe()
+			})
+
+			Convey("When "+other.User+" destroys the service of "+owner.Group, func() {
+				sub, _ := n.ChanSubscribe("service.delete", deleteSub)
+
+				o, _ := other.ernest("service", "destroy", "--force", service)
+				Convey("Then it should be rejected", func() {
+					Info("And the cli should say the service does not exist", " ", 8)
+					So(o, ShouldContainSubstring, serviceNotFound)
+
+					_, err := waitMsgTime(deleteSub, 2*time.Second)
+					So(err, ShouldNotBeNil)
+
+					Info("And the service of "+owner.Group+" should still be stored", " ", 8)
+					s, err := getService(service)
+					So(err, ShouldBeNil)
+					So(s.Status, ShouldEqual, "done")
+				})
+
+				sub.Unsubscribe()
+			})
+		}
+	})
+}
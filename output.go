@@ -0,0 +1,329 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// An expected output is written one line per output line, with:
+//
+//   {name}       replaced by the value given for name, or by the pattern of
+//                the built in placeholders when no value is given
+//   re:<regex>   a line matching the regular expression, placeholders
+//                being replaced in it too
+//   ...          any number of lines
+//   unordered:   the lines up to the next end: line, in any order
+//
+// Any other line must be equal to the output one, ignoring colors and
+// trailing spaces.
+
+// placeholders are the patterns of the placeholders with no given value
+var placeholders = map[string]string{
+	"id":      `[0-9a-zA-Z-]+`,
+	"uuid":    `[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`,
+	"ip":      `\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}`,
+	"date":    `\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}\S*`,
+	"number":  `\d+`,
+	"any":     `.*`,
+	"service": `\S+`,
+}
+
+var placeholderPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+var colorPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+type expectedLine struct {
+	Source    string
+	Pattern   *regexp.Regexp
+	Skip      bool
+	Unordered []expectedLine
+}
+
+// parseExpected compiles an expected output, vars being the placeholder
+// values
+func parseExpected(expected string, vars map[string]string) ([]expectedLine, error) {
+	var lines []expectedLine
+	var block *expectedLine
+
+	for _, l := range outputLines(expected) {
+		switch {
+		case l == "...":
+			lines = append(lines, expectedLine{Source: l, Skip: true})
+		case l == "unordered:":
+			block = &expectedLine{Source: l}
+		case l == "end:" && block != nil:
+			lines = append(lines, *block)
+			block = nil
+		default:
+			p, err := linePattern(l, vars)
+			if err != nil {
+				return nil, err
+			}
+			if block != nil {
+				block.Unordered = append(block.Unordered, expectedLine{Source: l, Pattern: p})
+			} else {
+				lines = append(lines, expectedLine{Source: l, Pattern: p})
+			}
+		}
+	}
+	if block != nil {
+		return nil, fmt.Errorf("unordered block with no end:")
+	}
+
+	return lines, nil
+}
+
+func linePattern(l string, vars map[string]string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(l, "re:") {
+		re := placeholderPattern.ReplaceAllStringFunc(strings.TrimSpace(strings.TrimPrefix(l, "re:")), func(p string) string {
+			if pattern, ok := placeholderRegexp(p[1:len(p)-1], vars); ok {
+				return pattern
+			}
+			return p
+		})
+		return regexp.Compile("^" + re + "$")
+	}
+
+	var p strings.Builder
+	last := 0
+	for _, m := range placeholderPattern.FindAllStringSubmatchIndex(l, -1) {
+		p.WriteString(regexp.QuoteMeta(l[last:m[0]]))
+		if pattern, ok := placeholderRegexp(l[m[2]:m[3]], vars); ok {
+			p.WriteString(pattern)
+		} else {
+			p.WriteString(regexp.QuoteMeta(l[m[0]:m[1]]))
+		}
+		last = m[1]
+	}
+	p.WriteString(regexp.QuoteMeta(l[last:]))
+
+	return regexp.Compile("^" + p.String() + "$")
+}
+
+// placeholderRegexp returns what a placeholder matches, its given value or
+// its built in pattern
+func placeholderRegexp(name string, vars map[string]string) (string, bool) {
+	if v, ok := vars[name]; ok {
+		return regexp.QuoteMeta(v), true
+	}
+	pattern, ok := placeholders[name]
+	return pattern, ok
+}
+
+// outputLines splits an output into lines with no colors nor trailing
+// spaces, dropping the trailing empty ones
+func outputLines(output string) []string {
+	lines := strings.Split(colorPattern.ReplaceAllString(output, ""), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " \t\r")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// matchOutput checks an output against an expected one, returning why it
+// does not match. Secrets are redacted from the output, the expected one
+// and the placeholder values before matching, so expected outputs hold
+// their masks and no failure ever prints a secret
+func matchOutput(output, expected string, vars map[string]string) []string {
+	redacted := make(map[string]string, len(vars))
+	for name, v := range vars {
+		redacted[name] = redact(v)
+	}
+
+	lines, err := parseExpected(redact(expected), redacted)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	m := &outputMatch{output: outputLines(redact(output)), furthest: -1}
+	if m.match(lines, 0) {
+		return nil
+	}
+
+	got := "end of output"
+	if m.furthest < len(m.output) {
+		got = fmt.Sprintf("%q", m.output[m.furthest])
+	}
+	return []string{fmt.Sprintf("output line %d expected to be %q but found %s", m.furthest+1, m.expected, got)}
+}
+
+// outputMatch matches output lines keeping the furthest failure, the one
+// reported when nothing matches
+type outputMatch struct {
+	output   []string
+	furthest int
+	expected string
+}
+
+func (m *outputMatch) fail(line int, expected string) bool {
+	if line > m.furthest {
+		m.furthest, m.expected = line, expected
+	}
+	return false
+}
+
+func (m *outputMatch) match(lines []expectedLine, at int) bool {
+	if len(lines) == 0 {
+		if at < len(m.output) {
+			return m.fail(at, "end of output")
+		}
+		return true
+	}
+
+	e := lines[0]
+	switch {
+	case e.Skip:
+		for i := at; i <= len(m.output); i++ {
+			if m.match(lines[1:], i) {
+				return true
+			}
+		}
+		return false
+	case e.Unordered != nil:
+		end := at + len(e.Unordered)
+		if end > len(m.output) {
+			return m.fail(len(m.output), e.Unordered[0].Source)
+		}
+		if missing := unorderedMatch(e.Unordered, m.output[at:end]); missing != "" {
+			return m.fail(at, missing)
+		}
+		return m.match(lines[1:], end)
+	}
+
+	if at >= len(m.output) || !e.Pattern.MatchString(m.output[at]) {
+		return m.fail(at, e.Source)
+	}
+	return m.match(lines[1:], at+1)
+}
+
+// unorderedMatch pairs every expected line with a different output line,
+// returning the first expected line left with no pair
+func unorderedMatch(expected []expectedLine, output []string) string {
+	used := make([]bool, len(output))
+	var pair func(i int) bool
+	pair = func(i int) bool {
+		if i == len(expected) {
+			return true
+		}
+		for j, o := range output {
+			if !used[j] && expected[i].Pattern.MatchString(o) {
+				used[j] = true
+				if pair(i + 1) {
+					return true
+				}
+				used[j] = false
+			}
+		}
+		return false
+	}
+
+	if pair(0) {
+		return ""
+	}
+	for _, e := range expected {
+		found := false
+		for _, o := range output {
+			if e.Pattern.MatchString(o) {
+				found = true
+			}
+		}
+		if !found {
+			return e.Source
+		}
+	}
+	return expected[0].Source
+}
+
+// goldenPath returns the path of a golden output next to the definitions
+func goldenPath(name string) string {
+	_, filename, _, _ := runtime.Caller(0)
+	return path.Join(path.Dir(filename), "outputs", name+".golden")
+}
+
+// matchGolden checks an output against its golden file. With
+// UAT_UPDATE_GOLDEN set the golden file is written instead, with the vars
+// values replaced by their placeholders
+func matchGolden(name, output string, vars map[string]string) []string {
+	file := goldenPath(name)
+
+	if os.Getenv("UAT_UPDATE_GOLDEN") != "" {
+		if err := ioutil.WriteFile(file, []byte(goldenOutput(output, vars)), 0644); err != nil {
+			return []string{err.Error()}
+		}
+		return nil
+	}
+
+	expected, err := ioutil.ReadFile(file)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	return matchOutput(output, string(expected), vars)
+}
+
+// goldenOutput replaces the vars values of an output by their
+// placeholders, longest values first
+func goldenOutput(output string, vars map[string]string) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		if vars[name] != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return len(vars[names[i]]) > len(vars[names[j]]) })
+
+	lines := outputLines(redact(output))
+	for i := range lines {
+		for _, name := range names {
+			lines[i] = strings.Replace(lines[i], vars[name], "{"+name+"}", -1)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// parseTable reads the rows of an ascii table, as printed by the cli
+// lists, keyed by their lowercase header
+func parseTable(output string) []map[string]string {
+	var header []string
+	var rows []map[string]string
+
+	for _, l := range outputLines(output) {
+		l = strings.TrimSpace(l)
+		if !strings.HasPrefix(l, "|") {
+			continue
+		}
+
+		var cells []string
+		for _, c := range strings.Split(strings.Trim(l, "|"), "|") {
+			cells = append(cells, strings.TrimSpace(c))
+		}
+
+		if header == nil {
+			for _, c := range cells {
+				header = append(header, strings.ToLower(c))
+			}
+			continue
+		}
+
+		row := make(map[string]string)
+		for i, c := range cells {
+			if i < len(header) {
+				row[header[i]] = c
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
@@ -0,0 +1,205 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMatchOutput(t *testing.T) {
+	registerSecret("output test password", "ot_pwd_7")
+
+	cases := []struct {
+		description string
+		output      string
+		expected    string
+		vars        map[string]string
+		errs        []string
+	}{
+		{
+			"the same lines with colors and trailing spaces",
+			"\x1b[32mEnvironment creation requested\x1b[0m  \nSUCCESS\n\n",
+			"Environment creation requested\nSUCCESS",
+			nil,
+			nil,
+		},
+		{
+			"a different line",
+			"Environment creation requested\nERROR",
+			"Environment creation requested\nSUCCESS",
+			nil,
+			[]string{`output line 2 expected to be "SUCCESS" but found "ERROR"`},
+		},
+		{
+			"more lines than expected",
+			"a\nb",
+			"a",
+			nil,
+			[]string{`output line 2 expected to be "end of output" but found "b"`},
+		},
+		{
+			"fewer lines than expected",
+			"a",
+			"a\nb",
+			nil,
+			[]string{`output line 2 expected to be "b" but found end of output`},
+		},
+		{
+			"a given placeholder",
+			"Name : web1",
+			"Name : {service}",
+			map[string]string{"service": "web1"},
+			nil,
+		},
+		{
+			"a given placeholder with another value",
+			"Name : web2",
+			"Name : {service}",
+			map[string]string{"service": "web1"},
+			[]string{`output line 1 expected to be "Name : {service}" but found "Name : web2"`},
+		},
+		{
+			"a given placeholder value holding regexp characters",
+			"Name : axb",
+			"Name : {service}",
+			map[string]string{"service": "a.b"},
+			[]string{`output line 1 expected to be "Name : {service}" but found "Name : axb"`},
+		},
+		{
+			"built in placeholders",
+			"IP : 10.1.0.11 Build : 8 Date : 2016-10-19 10:00:00",
+			"IP : {ip} Build : {number} Date : {date}",
+			nil,
+			nil,
+		},
+		{
+			"a built in placeholder not matching",
+			"IP : 10.1.0",
+			"IP : {ip}",
+			nil,
+			[]string{`output line 1 expected to be "IP : {ip}" but found "IP : 10.1.0"`},
+		},
+		{
+			"an unknown placeholder",
+			"Name : {other}",
+			"Name : {other}",
+			nil,
+			nil,
+		},
+		{
+			"regular expression lines with placeholders",
+			"| fake-web1-stg-1 |   | 10.2.0.90 |",
+			`re:\|\s+fake-{service}-stg-1\s+\|\s+\|\s+{ip}\s+\|`,
+			map[string]string{"service": "web1"},
+			nil,
+		},
+		{
+			"a regular expression line not matching",
+			"Status : errored",
+			"re:Status : (done|in_progress)",
+			nil,
+			[]string{`output line 1 expected to be "re:Status : (done|in_progress)" but found "Status : errored"`},
+		},
+		{
+			"skipped lines",
+			"a\nb\nc\nz",
+			"a\n...\nz",
+			nil,
+			nil,
+		},
+		{
+			"no lines to skip",
+			"a\nz",
+			"a\n...\nz",
+			nil,
+			nil,
+		},
+		{
+			"skipped lines up to the end",
+			"a\nb\nc",
+			"a\n...",
+			nil,
+			nil,
+		},
+		{
+			"skipped lines never followed by the expected one",
+			"a\nb\nc",
+			"a\n...\nz",
+			nil,
+			[]string{`output line 4 expected to be "z" but found end of output`},
+		},
+		{
+			"unordered lines",
+			"start\nc\na\nb\nend",
+			"start\nunordered:\na\nb\nc\nend:\nend",
+			nil,
+			nil,
+		},
+		{
+			"unordered lines with one missing",
+			"start\nc\na\nd\nend",
+			"start\nunordered:\na\nb\nc\nend:\nend",
+			nil,
+			[]string{`output line 2 expected to be "b" but found "c"`},
+		},
+		{
+			"unordered lines where a pattern can take any line",
+			"web-2\nweb-1",
+			"unordered:\nre:web-\\d\nweb-2\nend:",
+			nil,
+			nil,
+		},
+		{
+			"unordered lines past the end of the output",
+			"a",
+			"unordered:\na\nb\nend:",
+			nil,
+			[]string{`output line 2 expected to be "a" but found end of output`},
+		},
+		{
+			"an unordered block with no end",
+			"a",
+			"unordered:\na",
+			nil,
+			[]string{"unordered block with no end:"},
+		},
+		{
+			"a secret expected by its mask",
+			"Password : ot_pwd_7",
+			"Password : [redacted output test password]",
+			nil,
+			nil,
+		},
+		{
+			"a secret expected by its value",
+			"Password : ot_pwd_7",
+			"Password : ot_pwd_7",
+			nil,
+			nil,
+		},
+		{
+			"a secret not matching",
+			"Password : ot_pwd_7",
+			"Password : ot_pwd_7 {service}",
+			map[string]string{"service": "ot_pwd_7"},
+			[]string{`output line 1 expected to be "Password : [redacted output test password] {service}" but found "Password : [redacted output test password]"`},
+		},
+	}
+
+	Convey("Given an expected output", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When the output has "+c.description, func() {
+				errs := matchOutput(c.output, c.expected, c.vars)
+
+				Convey("Then it should report the first line not matching", func() {
+					So(errs, ShouldResemble, c.errs)
+				})
+			})
+		}
+	})
+}
@@ -0,0 +1,267 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"strings"
+)
+
+type serviceRecord struct {
+	Name     string
+	Status   string
+	Endpoint string
+	Updated  string
+}
+
+type buildRecord struct {
+	ID      string
+	Name    string
+	Status  string
+	Updated string
+}
+
+type datacenterRecord struct {
+	ID   string
+	Name string
+	Type string
+}
+
+type userRecord struct {
+	ID    string
+	Name  string
+	Group string
+}
+
+type groupRecord struct {
+	ID   string
+	Name string
+}
+
+type networkRecord struct {
+	Name   string
+	Subnet string
+}
+
+type instanceRecord struct {
+	Name     string
+	IP       string
+	PublicIP string
+}
+
+// serviceInfo is the output of service info, its sections being read from
+// the table following their title
+type serviceInfo struct {
+	Name       string
+	Status     string
+	Datacenter string
+	Endpoint   string
+	Networks   []networkRecord
+	Instances  []instanceRecord
+}
+
+// cell returns the first column of a row found under any of names, as the
+// cli headers changed across versions
+func cell(row map[string]string, names ...string) string {
+	for _, name := range names {
+		if v, ok := row[name]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseServiceList reads the output of service list
+func parseServiceList(output string) []serviceRecord {
+	var records []serviceRecord
+	for _, row := range parseTable(output) {
+		records = append(records, serviceRecord{
+			Name:     cell(row, "name"),
+			Status:   cell(row, "status"),
+			Endpoint: cell(row, "endpoint"),
+			Updated:  cell(row, "updated", "last updated"),
+		})
+	}
+	return records
+}
+
+// findServiceRecord returns the listed service named name
+func findServiceRecord(records []serviceRecord, name string) (serviceRecord, bool) {
+	for _, r := range records {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return serviceRecord{}, false
+}
+
+// findDatacenterRecord returns the listed datacenter named name
+func findDatacenterRecord(records []datacenterRecord, name string) (datacenterRecord, bool) {
+	for _, r := range records {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return datacenterRecord{}, false
+}
+
+// findUserRecord returns the listed user named name
+func findUserRecord(records []userRecord, name string) (userRecord, bool) {
+	for _, r := range records {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return userRecord{}, false
+}
+
+// findGroupRecord returns the listed group named name
+func findGroupRecord(records []groupRecord, name string) (groupRecord, bool) {
+	for _, r := range records {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return groupRecord{}, false
+}
+
+// parseServiceHistory reads the output of service history
+func parseServiceHistory(output string) []buildRecord {
+	var records []buildRecord
+	for _, row := range parseTable(output) {
+		records = append(records, buildRecord{
+			ID:      cell(row, "build id", "id"),
+			Name:    cell(row, "name"),
+			Status:  cell(row, "status"),
+			Updated: cell(row, "updated", "last updated"),
+		})
+	}
+	return records
+}
+
+// parseDatacenterList reads the output of datacenter list
+func parseDatacenterList(output string) []datacenterRecord {
+	var records []datacenterRecord
+	for _, row := range parseTable(output) {
+		records = append(records, datacenterRecord{
+			ID:   cell(row, "id"),
+			Name: cell(row, "name"),
+			Type: cell(row, "type"),
+		})
+	}
+	return records
+}
+
+// parseUserList reads the output of user list
+func parseUserList(output string) []userRecord {
+	var records []userRecord
+	for _, row := range parseTable(output) {
+		records = append(records, userRecord{
+			ID:    cell(row, "id"),
+			Name:  cell(row, "name", "username", "user"),
+			Group: cell(row, "group", "group id", "group_id"),
+		})
+	}
+	return records
+}
+
+// parseGroupList reads the output of group list
+func parseGroupList(output string) []groupRecord {
+	var records []groupRecord
+	for _, row := range parseTable(output) {
+		records = append(records, groupRecord{
+			ID:   cell(row, "id"),
+			Name: cell(row, "name"),
+		})
+	}
+	return records
+}
+
+// parseServiceInfo reads the output of service info, made of key: value
+// lines and titled tables
+func parseServiceInfo(output string) serviceInfo {
+	info := serviceInfo{}
+	fields := make(map[string]string)
+
+	for title, rows := range parseSections(output, fields) {
+		switch {
+		case strings.HasPrefix(title, "network"):
+			for _, row := range rows {
+				info.Networks = append(info.Networks, networkRecord{
+					Name:   cell(row, "name"),
+					Subnet: cell(row, "ip range", "subnet", "range"),
+				})
+			}
+		case strings.HasPrefix(title, "instance"):
+			for _, row := range rows {
+				info.Instances = append(info.Instances, instanceRecord{
+					Name:     cell(row, "name"),
+					IP:       cell(row, "ip", "private ip"),
+					PublicIP: cell(row, "public ip"),
+				})
+			}
+		}
+	}
+
+	info.Name = cell(fields, "name")
+	info.Status = cell(fields, "status")
+	info.Datacenter = cell(fields, "datacenter", "vpc")
+	info.Endpoint = cell(fields, "endpoint")
+
+	return info
+}
+
+// parseSections splits an output into the tables it holds, keyed by the
+// lowercase title printed before each of them, key: value lines being
+// stored on fields even when their value is empty
+func parseSections(output string, fields map[string]string) map[string][]map[string]string {
+	sections := make(map[string][]map[string]string)
+
+	title := ""
+	var table []string
+	flush := func() {
+		if len(table) > 0 {
+			sections[title] = parseTable(strings.Join(table, "\n"))
+			table = nil
+		}
+	}
+
+	lines := outputLines(output)
+	for i, l := range lines {
+		t := strings.TrimSpace(l)
+		switch {
+		case isTableLine(t):
+			table = append(table, t)
+		case t == "":
+		default:
+			flush()
+			key, value := t, ""
+			if j := strings.Index(t, ":"); j >= 0 {
+				key, value = strings.TrimSpace(t[:j]), strings.TrimSpace(t[j+1:])
+			}
+			if value == "" && startsTable(lines[i+1:]) {
+				title = strings.ToLower(key)
+			} else if key != t {
+				fields[strings.ToLower(key)] = value
+			}
+		}
+	}
+	flush()
+
+	return sections
+}
+
+func isTableLine(l string) bool {
+	return strings.HasPrefix(l, "|") || strings.HasPrefix(l, "+")
+}
+
+// startsTable reports if the first non empty line is part of a table
+func startsTable(lines []string) bool {
+	for _, l := range lines {
+		if t := strings.TrimSpace(l); t != "" {
+			return isTableLine(t)
+		}
+	}
+	return false
+}
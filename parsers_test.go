@@ -0,0 +1,234 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fixture returns an ernest-cli output kept on testdata
+func fixture(name string) string {
+	data, err := ioutil.ReadFile(path.Join("testdata", name))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return string(data)
+}
+
+// captureFixture returns an ernest-cli output, rewriting its fixture on
+// testdata from it, redacted, when UAT_UPDATE_FIXTURES is set
+func captureFixture(name, output string) string {
+	if os.Getenv("UAT_UPDATE_FIXTURES") != "" {
+		if err := ioutil.WriteFile(path.Join("testdata", name), []byte(redact(output)), 0644); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	return output
+}
+
+func TestParsersOnCli(t *testing.T) {
+	var suffix = strconv.Itoa(rand.Intn(9999999))
+	service := "parse" + suffix
+
+	basicSetup("vcloud")
+	ernest("service", "apply", getDefinitionPath("vse1.yml", service))
+
+	cli := func(fixture string, args ...string) string {
+		o, _ := ernest(args...)
+		return captureFixture(fixture, o)
+	}
+	admin := func(fixture string, args ...string) string {
+		o, _ := ernestAs(admin_usr, args...)
+		return captureFixture(fixture, o)
+	}
+
+	Convey("Given I applied a service with the cli", t, func() {
+		Convey("When I parse the outputs the cli prints for it", func() {
+			services := parseServiceList(cli("service_list.txt", "service", "list"))
+			builds := parseServiceHistory(cli("service_history.txt", "service", "history", service))
+			info := parseServiceInfo(cli("service_info.txt", "service", "info", service))
+			datacenters := parseDatacenterList(cli("datacenter_list.txt", "datacenter", "list"))
+			users := parseUserList(admin("user_list.txt", "user", "list"))
+			groups := parseGroupList(admin("group_list.txt", "group", "list"))
+
+			Convey("Then they should hold what the harness created", func() {
+				s, ok := findServiceRecord(services, service)
+				So(ok, ShouldBeTrue)
+				So(s.Status, ShouldEqual, "done")
+
+				So(len(builds), ShouldEqual, 1)
+				So(builds[0].Status, ShouldEqual, "done")
+
+				So(info.Name, ShouldEqual, service)
+				So(info.Status, ShouldEqual, "done")
+				So(info.Networks, ShouldContain, networkRecord{Name: "fake-" + service + "-web", Subnet: "10.1.0.0/24"})
+
+				d, ok := findDatacenterRecord(datacenters, "fake")
+				So(ok, ShouldBeTrue)
+				So(d.Type, ShouldContainSubstring, "vcloud")
+
+				_, ok = findUserRecord(users, default_usr)
+				So(ok, ShouldBeTrue)
+				_, ok = findGroupRecord(groups, "test")
+				So(ok, ShouldBeTrue)
+			})
+		})
+	})
+}
+
+func TestParsers(t *testing.T) {
+	cases := []struct {
+		command string
+		fixture string
+		parse   func(output string) interface{}
+		parsed  interface{}
+	}{
+		{
+			"service list",
+			"service_list.txt",
+			func(o string) interface{} { return parseServiceList(o) },
+			[]serviceRecord{
+				{Name: "r3test1", Status: "done", Endpoint: "172.16.186.44", Updated: "2016-10-19 10:02:11.517411 +0000 UTC"},
+				{Name: "r3vse2", Status: "errored", Updated: "2016-10-19 10:05:43.101938 +0000 UTC"},
+				{Name: "corn4242", Status: "in_progress", Updated: "2016-10-19 10:06:01.912004 +0000 UTC"},
+			},
+		},
+		{
+			"service history",
+			"service_history.txt",
+			func(o string) interface{} { return parseServiceHistory(o) },
+			[]buildRecord{
+				{ID: "3a7f3c4e-1c9a-4a4e-9d6c-5f7e2b1d0c11", Name: "r3test1", Status: "done", Updated: "2016-10-19 10:02:11.517411 +0000 UTC"},
+				{ID: "9b2d1e0f-7a4c-4f3b-8e21-c0d4a6b7e822", Name: "r3test1", Status: "errored", Updated: "2016-10-19 09:40:27.004215 +0000 UTC"},
+			},
+		},
+		{
+			"datacenter list",
+			"datacenter_list.txt",
+			func(o string) interface{} { return parseDatacenterList(o) },
+			[]datacenterRecord{
+				{ID: "1", Name: "fake", Type: "vcloud-fake"},
+				{ID: "2", Name: "fakeaws", Type: "aws-fake"},
+			},
+		},
+		{
+			"user list",
+			"user_list.txt",
+			func(o string) interface{} { return parseUserList(o) },
+			[]userRecord{
+				{ID: "1", Name: "ci_admin", Group: "0"},
+				{ID: "2", Name: "usr", Group: "1"},
+				{ID: "3", Name: "tena42_usr", Group: "2"},
+			},
+		},
+		{
+			"group list",
+			"group_list.txt",
+			func(o string) interface{} { return parseGroupList(o) },
+			[]groupRecord{{ID: "1", Name: "test"}, {ID: "2", Name: "tena42"}},
+		},
+		{
+			"service info",
+			"service_info.txt",
+			func(o string) interface{} { return parseServiceInfo(o) },
+			serviceInfo{
+				Name:   "r3vse1",
+				Status: "done",
+				Networks: []networkRecord{
+					{Name: "fake-r3vse1-db", Subnet: "10.2.0.0/24"},
+					{Name: "fake-r3vse1-web", Subnet: "10.1.0.0/24"},
+				},
+				Instances: []instanceRecord{
+					{Name: "fake-r3vse1-web-1", IP: "10.1.0.11"},
+					{Name: "fake-r3vse1-stg-1", IP: "10.2.0.90", PublicIP: "1.1.1.1"},
+				},
+			},
+		},
+	}
+
+	Convey("Given the outputs of ernest-cli", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When I parse the output of "+c.command, func() {
+				parsed := c.parse(fixture(c.fixture))
+
+				Convey("Then every row should be read", func() {
+					So(parsed, ShouldResemble, c.parsed)
+				})
+			})
+		}
+	})
+}
+
+func TestParseSections(t *testing.T) {
+	table := "+------+\n| NAME |\n+------+\n| web  |\n+------+"
+
+	cases := []struct {
+		description string
+		output      string
+		fields      map[string]string
+		sections    map[string][]map[string]string
+	}{
+		{
+			"a field with an empty value before a title",
+			"Endpoint :\n\nInstances:\n" + table,
+			map[string]string{"endpoint": ""},
+			map[string][]map[string]string{"instances": {{"name": "web"}}},
+		},
+		{
+			"a field with an empty value last",
+			"Name : web\nEndpoint :",
+			map[string]string{"name": "web", "endpoint": ""},
+			map[string][]map[string]string{},
+		},
+		{
+			"values holding colons",
+			"Date : 2016-10-19 10:02:11 +0000 UTC\nURL: https://ernest.local:443",
+			map[string]string{"date": "2016-10-19 10:02:11 +0000 UTC", "url": "https://ernest.local:443"},
+			map[string][]map[string]string{},
+		},
+		{
+			"a title with no colon and a blank line before its table",
+			"Networks\n\n" + table,
+			map[string]string{},
+			map[string][]map[string]string{"networks": {{"name": "web"}}},
+		},
+		{
+			"a table with no title",
+			table,
+			map[string]string{},
+			map[string][]map[string]string{"": {{"name": "web"}}},
+		},
+		{
+			"a line that is neither a field nor a title",
+			"Environment creation requested\nName : web",
+			map[string]string{"name": "web"},
+			map[string][]map[string]string{},
+		},
+	}
+
+	Convey("Given the output of a cli command", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When it has "+c.description, func() {
+				fields := make(map[string]string)
+				sections := parseSections(c.output, fields)
+
+				Convey("Then its fields and tables should be read apart", func() {
+					So(fields, ShouldResemble, c.fields)
+					So(sections, ShouldResemble, c.sections)
+				})
+			})
+		}
+	})
+}
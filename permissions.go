@@ -0,0 +1,131 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// adminOnly is what the cli prints when a user runs an admin command
+const adminOnly = "You don't have permissions to perform this action"
+
+// deniedMessages are the cli messages meaning a command was refused
+var deniedMessages = []string{adminOnly, serviceNotFound, datacenterNotFound}
+
+// permission declares which roles are allowed to run a command, args builds
+// the arguments for a given role, succeeded tells from the output of a role
+// and the state it left if the command did what it is meant to, and denials
+// holds the message each denied role gets
+type permission struct {
+	Command   string
+	Args      func(role string) []string
+	Succeeded func(role, output string) bool
+	Allowed   map[string]bool
+	Denials   map[string]string
+}
+
+type permissionResult struct {
+	Command  string
+	Role     string
+	Expected bool
+	Actual   bool
+	Denial   string
+	Output   string
+}
+
+// Mismatch reports if the command outcome differs from the declared one, an
+// allowed command being expected to succeed and a denial to come with its
+// message, so a command failing for any other reason is a mismatch either
+// way
+func (r permissionResult) Mismatch() bool {
+	if r.Expected != r.Actual {
+		return true
+	}
+	return !r.Expected && !strings.Contains(r.Output, r.Denial)
+}
+
+// denied reports if a cli output means the command was refused
+func denied(output string) bool {
+	for _, m := range deniedMessages {
+		if strings.Contains(output, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPermissions runs every command as every role, runners being the cli
+// of each role, a command counting as allowed only when it succeeded
+func checkPermissions(permissions []permission, roles []string, runners map[string]func(...string) (string, error)) []permissionResult {
+	var results []permissionResult
+
+	for _, p := range permissions {
+		for _, role := range roles {
+			o, _ := runners[role](p.Args(role)...)
+			results = append(results, permissionResult{
+				Command:  p.Command,
+				Role:     role,
+				Expected: p.Allowed[role],
+				Actual:   p.Succeeded(role, o),
+				Denial:   p.Denials[role],
+				Output:   o,
+			})
+		}
+	}
+
+	return results
+}
+
+// permissionMatrix renders a command by role table of the results, marking
+// every mismatch with what was expected
+func permissionMatrix(results []permissionResult, roles []string) string {
+	cells := make(map[string]map[string]permissionResult)
+	var commands []string
+	width := len("command")
+
+	for _, r := range results {
+		if _, ok := cells[r.Command]; !ok {
+			cells[r.Command] = make(map[string]permissionResult)
+			commands = append(commands, r.Command)
+		}
+		cells[r.Command][r.Role] = r
+		if len(r.Command) > width {
+			width = len(r.Command)
+		}
+	}
+
+	cell := func(r permissionResult) string {
+		outcome := map[bool]string{true: "allow", false: "deny"}
+		actual := outcome[r.Actual]
+		if !r.Actual && !denied(r.Output) {
+			actual = "fail"
+		}
+		switch {
+		case r.Expected != r.Actual:
+			return fmt.Sprintf("%s (expected %s) !!", actual, outcome[r.Expected])
+		case r.Mismatch():
+			return "deny (unexpected message) !!"
+		}
+		return actual
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-*s", width+2, "command")
+	for _, role := range roles {
+		fmt.Fprintf(&b, "%-28s", role)
+	}
+	b.WriteString("\n")
+
+	for _, c := range commands {
+		fmt.Fprintf(&b, "%-*s", width+2, c)
+		for _, role := range roles {
+			fmt.Fprintf(&b, "%-28s", cell(cells[c][role]))
+		}
+		b.WriteString("\n")
+	}
+
+	return redact(b.String())
+}
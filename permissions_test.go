@@ -0,0 +1,244 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	roleAdmin    = "admin"
+	roleMember   = "member"
+	roleOutsider = "outsider"
+)
+
+var permissionRoles = []string{roleAdmin, roleMember, roleOutsider}
+
+func allowed(roles ...string) map[string]bool {
+	a := make(map[string]bool)
+	for _, r := range roles {
+		a[r] = true
+	}
+	return a
+}
+
+// deniedWith returns the message every given role is denied with
+func deniedWith(message string, roles ...string) map[string]string {
+	d := make(map[string]string)
+	for _, r := range roles {
+		d[r] = message
+	}
+	return d
+}
+
+func TestPermissions(t *testing.T) {
+	var suffix = strconv.Itoa(rand.Intn(9999999))
+
+	owner := newTenant("perm" + suffix)
+	outsider := newTenant("out" + suffix)
+	service := "perm" + suffix
+
+	basicSetup("vcloud")
+	owner.setup()
+	outsider.setup()
+	owner.ernest("service", "apply", getDefinitionPathOn("inst1.yml", service, owner.Datacenter))
+
+	// each role works on users of its own, so what a role changes never
+	// decides the outcome of the next one: joiner is added to the owner
+	// group and leaver, already on it, is removed from it. The services,
+	// datacenters, users and groups each role deletes are created upfront
+	// the same way
+	joiner := func(role string) string { return "join" + role + suffix }
+	leaver := func(role string) string { return "leave" + role + suffix }
+	doomed := func(role string) string { return "del" + role + suffix }
+	dcPassword := func(role string) string { return owner.Password + "-" + role }
+	for _, role := range permissionRoles {
+		registerSecret(owner.Datacenter+" "+role+" password", dcPassword(role))
+		ernestAs(admin_usr, "user", "create", joiner(role), default_pwd)
+		ernestAs(admin_usr, "user", "create", leaver(role), default_pwd)
+		ernestAs(admin_usr, "group", "add-user", leaver(role), owner.Group)
+		ernestAs(admin_usr, "user", "create", doomed(role), default_pwd)
+		ernestAs(admin_usr, "group", "create", doomed(role))
+		owner.ernest("service", "apply", getDefinitionPathOn("inst1.yml", doomed(role), owner.Datacenter))
+		owner.ernest("datacenter", "create", "aws", doomed(role), "--region", "fake", "--token", "fake", "--secret", default_aws_secret, "--fake")
+	}
+
+	runners := map[string]func(...string) (string, error){
+		roleAdmin: func(args ...string) (string, error) {
+			return ernestAs(admin_usr, args...)
+		},
+		roleMember:   owner.ernest,
+		roleOutsider: outsider.ernest,
+	}
+
+	same := func(args ...string) func(string) []string {
+		return func(role string) []string { return args }
+	}
+
+	// the success signals of each command, read from its output or from
+	// what the admin, the role or the store see once it ran
+	tabled := func(role, o string) bool { return startsTable(outputLines(o)) }
+	user := func(name string) (userRecord, bool) {
+		o, _ := ernestAs(admin_usr, "user", "list")
+		return findUserRecord(parseUserList(o), name)
+	}
+	group := func(name string) bool {
+		o, _ := ernestAs(admin_usr, "group", "list")
+		_, ok := findGroupRecord(parseGroupList(o), name)
+		return ok
+	}
+	// the user list shows the group of a user by its id
+	inOwnerGroup := func(name string) (bool, bool) {
+		o, _ := ernestAs(admin_usr, "group", "list")
+		g, _ := findGroupRecord(parseGroupList(o), owner.Group)
+		u, ok := user(name)
+		return ok, u.Group != "" && (u.Group == g.ID || u.Group == g.Name)
+	}
+	datacenter := func(role, name string) bool {
+		o, _ := runners[role]("datacenter", "list")
+		_, ok := findDatacenterRecord(parseDatacenterList(o), name)
+		return ok
+	}
+	applied, _ := getService(service)
+
+	permissions := []permission{
+		{"user list", same("user", "list"), tabled, allowed(roleAdmin, roleMember, roleOutsider), nil},
+		{"user create", func(role string) []string {
+			return []string{"user", "create", role + suffix, default_pwd}
+		}, func(role, o string) bool {
+			_, ok := user(role + suffix)
+			return ok
+		}, allowed(roleAdmin), deniedWith(adminOnly, roleMember, roleOutsider)},
+		{"group list", same("group", "list"), tabled, allowed(roleAdmin), deniedWith(adminOnly, roleMember, roleOutsider)},
+		{"group create", func(role string) []string {
+			return []string{"group", "create", role + suffix}
+		}, func(role, o string) bool {
+			return group(role + suffix)
+		}, allowed(roleAdmin), deniedWith(adminOnly, roleMember, roleOutsider)},
+		{"group add-user", func(role string) []string {
+			return []string{"group", "add-user", joiner(role), owner.Group}
+		}, func(role, o string) bool {
+			listed, in := inOwnerGroup(joiner(role))
+			return listed && in
+		}, allowed(roleAdmin), deniedWith(adminOnly, roleMember, roleOutsider)},
+		{"group remove-user", func(role string) []string {
+			return []string{"group", "remove-user", leaver(role), owner.Group}
+		}, func(role, o string) bool {
+			listed, in := inOwnerGroup(leaver(role))
+			return listed && !in
+		}, allowed(roleAdmin), deniedWith(adminOnly, roleMember, roleOutsider)},
+		{"datacenter list", same("datacenter", "list"), tabled, allowed(roleAdmin, roleMember, roleOutsider), nil},
+		{"datacenter create", func(role string) []string {
+			return []string{"datacenter", "create", "aws", role + suffix, "--region", "fake", "--token", "fake", "--secret", default_aws_secret, "--fake"}
+		}, func(role, o string) bool {
+			return datacenter(role, role+suffix)
+		}, allowed(roleAdmin, roleMember, roleOutsider), nil},
+		{"datacenter update", func(role string) []string {
+			return []string{"datacenter", "update", "vcloud", owner.Datacenter, "--user", owner.User, "--password", dcPassword(role)}
+		}, func(role, o string) bool {
+			d, err := getDatacenter(owner.Datacenter)
+			return err == nil && d.Password == dcPassword(role)
+		}, allowed(roleAdmin, roleMember), deniedWith(datacenterNotFound, roleOutsider)},
+		{"service list", same("service", "list"), func(role, o string) bool {
+			_, ok := findServiceRecord(parseServiceList(o), service)
+			// the outsider sees none of the owner services
+			return ok || role == roleOutsider && tabled(role, o)
+		}, allowed(roleAdmin, roleMember, roleOutsider), nil},
+		{"service info", same("service", "info", service), func(role, o string) bool {
+			return parseServiceInfo(o).Name == service
+		}, allowed(roleAdmin, roleMember), deniedWith(serviceNotFound, roleOutsider)},
+		{"service definition", same("service", "definition", service), func(role, o string) bool {
+			return strings.Contains(o, "name: "+service)
+		}, allowed(roleAdmin, roleMember), deniedWith(serviceNotFound, roleOutsider)},
+		{"service history", same("service", "history", service), func(role, o string) bool {
+			return len(parseServiceHistory(o)) > 0
+		}, allowed(roleAdmin, roleMember), deniedWith(serviceNotFound, roleOutsider)},
+		{"service apply", same("service", "apply", getDefinitionPathOn("inst1.yml", service, owner.Datacenter)), func(role, o string) bool {
+			s, err := getService(service)
+			if err != nil || s.ID == applied.ID || s.Status != "done" {
+				return false
+			}
+			applied = s
+			return true
+		}, allowed(roleAdmin, roleMember), deniedWith(datacenterNotFound, roleOutsider)},
+
+		// the destructive commands run last, once nothing else needs what
+		// they delete
+		{"service destroy", func(role string) []string {
+			return []string{"service", "destroy", doomed(role), "--force"}
+		}, func(role, o string) bool {
+			builds, err := findServices(doomed(role))
+			return err == nil && len(builds) == 0
+		}, allowed(roleAdmin, roleMember), deniedWith(serviceNotFound, roleOutsider)},
+		{"datacenter delete", func(role string) []string {
+			return []string{"datacenter", "delete", doomed(role)}
+		}, func(role, o string) bool {
+			return !denied(o) && !datacenter(roleMember, doomed(role))
+		}, allowed(roleAdmin, roleMember), deniedWith(datacenterNotFound, roleOutsider)},
+		{"user delete", func(role string) []string {
+			return []string{"user", "delete", doomed(role)}
+		}, func(role, o string) bool {
+			_, ok := user(doomed(role))
+			return !denied(o) && !ok
+		}, allowed(roleAdmin), deniedWith(adminOnly, roleMember, roleOutsider)},
+		{"group delete", func(role string) []string {
+			return []string{"group", "delete", doomed(role)}
+		}, func(role, o string) bool {
+			return !denied(o) && !group(doomed(role))
+		}, allowed(roleAdmin), deniedWith(adminOnly, roleMember, roleOutsider)},
+	}
+
+	Convey("Given I have an admin, a group member and an outsider", t, func() {
+		Convey("When each of them runs every command family", func() {
+			results := checkPermissions(permissions, permissionRoles, runners)
+
+			Convey("Then every outcome should match the permission table", func() {
+				mismatches := 0
+				for _, r := range results {
+					if r.Mismatch() {
+						mismatches++
+					}
+				}
+				if mismatches > 0 {
+					Printf("\n%s", permissionMatrix(results, permissionRoles))
+				}
+				So(mismatches, ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func TestPermissionMismatch(t *testing.T) {
+	cases := []struct {
+		description string
+		result      permissionResult
+		mismatch    bool
+	}{
+		{"an allowed command run", permissionResult{Expected: true, Actual: true, Output: "Group created"}, false},
+		{"a denial with its message", permissionResult{Denial: adminOnly, Output: adminOnly}, false},
+		{"a denial with another message", permissionResult{Denial: adminOnly, Output: serviceNotFound}, true},
+		{"an allowed command refused", permissionResult{Expected: true, Output: adminOnly}, true},
+		{"an allowed command crashing", permissionResult{Expected: true, Output: "panic: runtime error: invalid memory address"}, true},
+		{"an allowed command printing nothing", permissionResult{Expected: true}, true},
+		{"a denied command run", permissionResult{Actual: true, Denial: adminOnly, Output: "Group created"}, true},
+		{"a denied command failing otherwise", permissionResult{Denial: adminOnly, Output: "dial tcp: connection refused"}, true},
+	}
+
+	Convey("Given the outcome of a command", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When it is "+c.description, func() {
+				Convey("Then it should be checked against the permission table", func() {
+					So(c.result.Mismatch(), ShouldEqual, c.mismatch)
+				})
+			})
+		}
+	})
+}
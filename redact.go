@@ -0,0 +1,172 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// secretFields are the payload fields always masked, whatever their value
+var secretFields = map[string]bool{
+	"datacenter_password": true,
+	"datacenter_secret":   true,
+	"datacenter_token":    true,
+}
+
+// redactedPattern matches a mask, naming the secret when it is a known one
+var redactedPattern = regexp.MustCompile(`\[redacted( [^\]]+)?\]`)
+
+// redactedAs is the mask of a secret, name being empty for unknown values
+// on secret fields
+func redactedAs(name string) string {
+	if name == "" {
+		return "[redacted]"
+	}
+	return "[redacted " + name + "]"
+}
+
+// redact masks every known secret value found on s, scanning it once so
+// masks are never redacted again
+func redact(s string) string {
+	values := knownSecrets()
+	if len(values) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		masked := false
+		if i == 0 || !isWordByte(s[i-1]) {
+			for _, v := range values {
+				end := i + len(v)
+				if strings.HasPrefix(s[i:], v) && (end == len(s) || !isWordByte(s[end])) {
+					b.WriteString(redactedAs(secretName(v)))
+					i = end
+					masked = true
+					break
+				}
+			}
+		}
+		if !masked {
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// unmasked removes the masks of a redacted string
+func unmasked(s string) string {
+	return redactedPattern.ReplaceAllString(s, "")
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// redactJSON masks the secret fields and every known secret value of a
+// payload, payloads not being json are redacted as plain text. Only the
+// strings holding a secret are rewritten, the rest of the payload is kept
+// byte for byte
+func redactJSON(data []byte) []byte {
+	if !json.Valid(data) {
+		return []byte(redact(string(data)))
+	}
+
+	// containers holds, for every open object or array, the key the strings
+	// found directly on it are under and the key of the object holding them
+	type container struct {
+		object    bool
+		parent    string
+		name      string
+		key       string
+		expectKey bool
+	}
+	var containers []*container
+	context := func() (string, string) {
+		if len(containers) == 0 {
+			return "", ""
+		}
+		c := containers[len(containers)-1]
+		if c.object {
+			return c.name, c.key
+		}
+		return c.parent, c.name
+	}
+
+	var b bytes.Buffer
+	for i := 0; i < len(data); {
+		switch c := data[i]; c {
+		case '{', '[':
+			parent, key := context()
+			containers = append(containers, &container{object: c == '{', parent: parent, name: key, expectKey: c == '{'})
+			b.WriteByte(c)
+			i++
+		case '}', ']':
+			containers = containers[:len(containers)-1]
+			b.WriteByte(c)
+			i++
+		case ',':
+			if len(containers) > 0 && containers[len(containers)-1].object {
+				containers[len(containers)-1].expectKey = true
+			}
+			b.WriteByte(c)
+			i++
+		case '"':
+			end := stringEnd(data, i)
+			raw := data[i:end]
+			i = end
+
+			var value string
+			json.Unmarshal(raw, &value)
+			if top := len(containers) - 1; top >= 0 && containers[top].object && containers[top].expectKey {
+				containers[top].key = value
+				containers[top].expectKey = false
+				b.Write(raw)
+				continue
+			}
+
+			parent, key := context()
+			redacted := redact(value)
+			if value != "" && (secretFields[key] || (parent == "service_options" && key == "password")) {
+				redacted = redactedAs(secretName(value))
+			}
+			if redacted == value {
+				b.Write(raw)
+				continue
+			}
+			b.Write(jsonString(redacted))
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.Bytes()
+}
+
+// stringEnd returns the index following the json string starting at i
+func stringEnd(data []byte, i int) int {
+	for j := i + 1; j < len(data); j++ {
+		switch data[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return len(data)
+}
+
+// jsonString encodes s as a json string, leaving html characters as they are
+func jsonString(s string) []byte {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return bytes.TrimRight(b.Bytes(), "\n")
+}
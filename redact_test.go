@@ -0,0 +1,171 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// ShouldEqualSecret is ShouldEqual for credentials, its failure message
+// telling if the values were empty but never printing them
+func ShouldEqualSecret(actual interface{}, expected ...interface{}) string {
+	if ShouldEqual(actual, expected...) == "" {
+		return ""
+	}
+	return fmt.Sprintf("Expected: %s\nActual:   %s\n(the secret values differ)", maskedValue(expected[0]), maskedValue(actual))
+}
+
+// ShouldNotContainSecret is ShouldNotContainSubstring for credentials, its
+// failure message naming neither the secret nor the text holding it
+func ShouldNotContainSecret(actual interface{}, expected ...interface{}) string {
+	if ShouldNotContainSubstring(actual, expected...) == "" {
+		return ""
+	}
+	return "Expected the value not to contain the secret but it does"
+}
+
+func maskedValue(v interface{}) string {
+	if v == nil || v == "" {
+		return "''"
+	}
+	return redactedAs("")
+}
+
+func TestShouldEqualSecret(t *testing.T) {
+	Convey("Given a credential assertion", t, func() {
+		Convey("When the values differ", func() {
+			msg := ShouldEqualSecret("tena_pwd", "tenb_pwd")
+
+			Convey("Then the failure should not print them", func() {
+				So(msg, ShouldNotEqual, "")
+				So(msg, ShouldNotContainSubstring, "tena_pwd")
+				So(msg, ShouldNotContainSubstring, "tenb_pwd")
+				So(msg, ShouldContainSubstring, "[redacted]")
+			})
+		})
+
+		Convey("When the value is missing", func() {
+			msg := ShouldEqualSecret("", "tenb_pwd")
+
+			Convey("Then the failure should say it is empty", func() {
+				So(msg, ShouldEqual, "Expected: [redacted]\nActual:   ''\n(the secret values differ)")
+			})
+		})
+
+		Convey("When a payload holds another secret", func() {
+			msg := ShouldNotContainSecret(`{"password":"tenb_pwd"}`, "tenb_pwd")
+
+			Convey("Then the failure should not print it", func() {
+				So(msg, ShouldNotEqual, "")
+				So(msg, ShouldNotContainSubstring, "tenb_pwd")
+			})
+		})
+
+		Convey("When the values match", func() {
+			Convey("Then it should pass", func() {
+				So(ShouldEqualSecret("tena_pwd", "tena_pwd"), ShouldEqual, "")
+			})
+		})
+	})
+}
+
+func TestRedactJSON(t *testing.T) {
+	registerSecret("redact test password", "rt_pwd_42")
+
+	cases := []struct {
+		description string
+		data        string
+		redacted    string
+	}{
+		{
+			"secret fields whatever their value",
+			`{"datacenter_password":"x","datacenter_token":"y","name":"web"}`,
+			`{"datacenter_password":"[redacted]","datacenter_token":"[redacted]","name":"web"}`,
+		},
+		{
+			"known secrets on any field",
+			`{"payload":"login -p rt_pwd_42 now","datacenter_password":"rt_pwd_42"}`,
+			`{"payload":"login -p [redacted redact test password] now","datacenter_password":"[redacted redact test password]"}`,
+		},
+		{
+			"the service options password only",
+			`{"service_options":{"user":"salt","password":"x"},"options":{"password":"y"}}`,
+			`{"service_options":{"user":"salt","password":"[redacted]"},"options":{"password":"y"}}`,
+		},
+		{
+			"secret fields on arrays of objects",
+			`{"items":[{"datacenter_secret":"x"},{"datacenter_secret":""}]}`,
+			`{"items":[{"datacenter_secret":"[redacted]"},{"datacenter_secret":""}]}`,
+		},
+		{
+			"a key named as a secret field holding an object",
+			`{"datacenter_password":{"value":"x"}}`,
+			`{"datacenter_password":{"value":"x"}}`,
+		},
+		{
+			"escaped html, unicode and numbers",
+			`{"html":"<b>&amp;","float":1.0,"exp":1e3,"big":12345678901234567890,"name":"café"}`,
+			`{"html":"<b>&amp;","float":1.0,"exp":1e3,"big":12345678901234567890,"name":"café"}`,
+		},
+		{
+			"whitespace and escaped quotes",
+			"{\n  \"a\": \"say \\\"rt_pwd_42\\\"\",\n  \"b\": [1, 2]\n}",
+			"{\n  \"a\": \"say \\\"[redacted redact test password]\\\"\",\n  \"b\": [1, 2]\n}",
+		},
+		{
+			"a payload that is not json",
+			`user rt_pwd_42 {`,
+			`user [redacted redact test password] {`,
+		},
+	}
+
+	Convey("Given a recorded payload", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When it holds "+c.description, func() {
+				redacted := string(redactJSON([]byte(c.data)))
+
+				Convey("Then only the secrets should be rewritten", func() {
+					So(redacted, ShouldEqual, c.redacted)
+				})
+			})
+		}
+	})
+}
+
+func TestRedactDefaultSecrets(t *testing.T) {
+	registerDefaultSecrets()
+
+	output := "Datacenter fake created\n" +
+		"ernest datacenter create vcloud fake --user " + default_usr + " --password " + default_pwd + "\n" +
+		"Logged in as " + admin_usr + " with " + admin_pwd + "\n"
+
+	Convey("Given the output of a command run with the default credentials", t, func() {
+		Convey("When it is redacted", func() {
+			redacted := redact(output)
+
+			Convey("Then the user and admin passwords should be masked", func() {
+				So(redacted, ShouldNotContainSubstring, default_pwd)
+				So(redacted, ShouldNotContainSubstring, admin_pwd)
+				So(redacted, ShouldContainSubstring, "--password [redacted user password]")
+				So(redacted, ShouldContainSubstring, "with [redacted admin password]")
+			})
+		})
+
+		Convey("When it does not match the expected output", func() {
+			errs := matchOutput(output, "Datacenter fake created\nre:ernest datacenter create vcloud fake --user {any} --password other", nil)
+
+			Convey("Then the diff should not show the user password", func() {
+				So(errs, ShouldNotBeEmpty)
+				So(strings.Join(errs, "\n"), ShouldNotContainSubstring, default_pwd)
+				So(strings.Join(errs, "\n"), ShouldContainSubstring, "[redacted user password]")
+			})
+		})
+	})
+}
@@ -0,0 +1,99 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats"
+)
+
+// recordedMsg is the on disk format of a recorded message, one per line
+type recordedMsg struct {
+	Subject  string          `json:"subject"`
+	Data     json.RawMessage `json:"data"`
+	Received time.Time       `json:"received"`
+}
+
+var runFile *os.File
+var runLock sync.Mutex
+
+// recordRun stores every message published on nats during the run in a
+// new file on dir, with their secrets redacted
+func recordRun(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	name := path.Join(dir, "run-"+runID()+".jsonl")
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	runFile = f
+
+	_, err = n.Subscribe(">", func(msg *nats.Msg) {
+		line, err := json.Marshal(recordedMsg{
+			Subject:  msg.Subject,
+			Data:     rawData(redactJSON(msg.Data)),
+			Received: time.Now(),
+		})
+		if err != nil {
+			return
+		}
+
+		runLock.Lock()
+		defer runLock.Unlock()
+		runFile.Write(append(line, '\n'))
+	})
+
+	return err
+}
+
+// runID identifies a run by the time it started, in nanoseconds, and the
+// process running it, so runs started at once never share a file
+func runID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 10) + "-" + strconv.Itoa(os.Getpid())
+}
+
+// loadRun reads a recorded run back into a timeline
+func loadRun(name string) (*timeline, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t := &timeline{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		msg := recordedMsg{}
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return nil, err
+		}
+		t.entries = append(t.entries, timelineEntry{
+			Subject:  msg.Subject,
+			Data:     []byte(msg.Data),
+			Received: msg.Received,
+		})
+	}
+
+	return t, scanner.Err()
+}
+
+func rawData(data []byte) json.RawMessage {
+	if json.Valid(data) {
+		return json.RawMessage(data)
+	}
+	quoted, _ := json.Marshal(string(data))
+	return json.RawMessage(quoted)
+}
@@ -0,0 +1,115 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const saltMaster = "list:salt-master.localdomain"
+
+// saltStep is a bootstrapped definition and the executions it runs, the
+// executions and bootstrap payloads of each step being checked by the
+// novse-salt and vse-salt scenarios
+type saltStep struct {
+	Definition string
+	Executions int
+}
+
+var saltSteps = []saltStep{
+	{"12.yml", 1},
+	{"13.yml", 1},
+	{"14.yml", 1},
+	{"15.yml", 1},
+	{"16.yml", 1},
+}
+
+func saltEvents(entries []timelineEntry) []executionEvent {
+	var events []executionEvent
+	for _, e := range entries {
+		ex := executionEvent{}
+		json.Unmarshal(e.Data, &ex)
+		events = append(events, ex)
+	}
+	return events
+}
+
+func instanceNames(entries []timelineEntry) []string {
+	var names []string
+	for _, e := range entries {
+		i := instanceEvent{}
+		json.Unmarshal(e.Data, &i)
+		names = append(names, i.InstanceName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSaltContract(t *testing.T) {
+	var suffix = strconv.Itoa(rand.Intn(9999999))
+
+	basicSetup("vcloud")
+
+	Convey("Given I have a configured ernest instance with salt", t, func() {
+		So(salt.User, ShouldNotEqual, "")
+		So(salt.Password, ShouldNotEqual, "")
+
+		for _, flavour := range []string{"novse", "vse"} {
+			service := "salt" + flavour + suffix
+
+			for _, step := range saltSteps {
+				step := step
+				def := flavour + step.Definition
+
+				Convey("When I apply a valid "+def+" definition", func() {
+					tl, err := recordTimeline("bootstrap.create.fake", "execution.create.fake", "instance.create.vcloud-fake", "instance.delete.vcloud-fake")
+					So(err, ShouldBeNil)
+					ernest("service", "apply", getDefinitionPath(def, service))
+
+					Convey("Then every salt call should use the salt credentials", func() {
+						So(tl.Wait("execution.create.fake", step.Executions), ShouldBeNil)
+
+						events := saltEvents(append(tl.Matching("bootstrap.create.fake"), tl.Matching("execution.create.fake")...))
+						for _, ex := range events {
+							So(ex.ExecutionType, ShouldEqual, "fake")
+							So(ex.ServiceOptions.User, ShouldEqual, salt.User)
+							So(ex.ServiceOptions.Password, ShouldEqualSecret, salt.Password)
+						}
+
+						Info("And only the new instances should be bootstrapped", " ", 8)
+						var bootstrapped []string
+						for _, ex := range saltEvents(tl.Matching("bootstrap.create.fake")) {
+							So(ex.ExecutionTarget, ShouldEqual, saltMaster)
+							bootstrapped = append(bootstrapped, strings.TrimPrefix(ex.Name, "Bootstrap "))
+						}
+						sort.Strings(bootstrapped)
+						So(bootstrapped, ShouldResemble, instanceNames(tl.Matching("instance.create.vcloud-fake")))
+
+						Info("And only the deleted instances should be removed from the salt master", " ", 8)
+						var cleanups []string
+						for _, ex := range saltEvents(tl.Matching("execution.create.fake")) {
+							if !strings.HasPrefix(ex.Name, "Cleanup Bootstrap ") {
+								continue
+							}
+							So(ex.ExecutionTarget, ShouldEqual, saltMaster)
+							cleanups = append(cleanups, strings.TrimPrefix(ex.Name, "Cleanup Bootstrap "))
+						}
+						sort.Strings(cleanups)
+						So(cleanups, ShouldResemble, instanceNames(tl.Matching("instance.delete.vcloud-fake")))
+					})
+
+					tl.Stop()
+				})
+			}
+		}
+	})
+}
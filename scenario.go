@@ -0,0 +1,633 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// scenario is an ordered list of steps run against the same service, as
+// described on a manifest of the scenarios directory
+type scenario struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Provider    string         `json:"provider"`
+	Tags        []string       `json:"tags"`
+	Steps       []scenarioStep `json:"steps"`
+	// IPs checks the addresses of the instances after every apply, the
+	// networks the service uses but does not create being given with
+	// their subnets
+	IPs      bool              `json:"ips,omitempty"`
+	Networks map[string]string `json:"networks,omitempty"`
+}
+
+// scenarioStep applies a definition, destroys the service or runs a cli
+// command, {service} being replaced by the service name on its arguments
+type scenarioStep struct {
+	Apply   string      `json:"apply,omitempty"`
+	Destroy bool        `json:"destroy,omitempty"`
+	Command []string    `json:"command,omitempty"`
+	Comment string      `json:"comment,omitempty"`
+	Tags    []string    `json:"tags,omitempty"`
+	Expect  expectation `json:"expect"`
+}
+
+// expectation is what a step must produce, events being the minimum number
+// of messages per subject and output an expected output as matchOutput
+// reads it. Before lists pairs of subjects whose messages must all come
+// before the first one of the second, follows names one of orderGraphs,
+// stored checks the step stored a new build with the ids of its resources,
+// along with mapping, the values stored on a component type field given as
+// type.field, and datacenter, the one it is stored on. Info is what the
+// output of service info must show once parsed
+type expectation struct {
+	Status     string              `json:"status,omitempty"`
+	Events     map[string]int      `json:"events,omitempty"`
+	Messages   []expectedMessage   `json:"messages,omitempty"`
+	Before     [][2]string         `json:"before,omitempty"`
+	Follows    string              `json:"follows,omitempty"`
+	Stored     bool                `json:"stored,omitempty"`
+	Mapping    map[string][]string `json:"mapping,omitempty"`
+	Datacenter *expectedDatacenter `json:"datacenter,omitempty"`
+	Info       *expectedInfo       `json:"info,omitempty"`
+	Output     string              `json:"output,omitempty"`
+	Golden     string              `json:"golden,omitempty"`
+}
+
+// checksOutput reports if an expectation checks what the cli prints, which
+// the http driver does not print
+func (e expectation) checksOutput() bool {
+	return e.Output != "" || e.Golden != "" || e.Info != nil
+}
+
+// expectedInfo is what service info must show, every network listed being
+// one of the networks it shows
+type expectedInfo struct {
+	Name     string          `json:"name,omitempty"`
+	Status   string          `json:"status,omitempty"`
+	Networks []networkRecord `json:"networks,omitempty"`
+}
+
+// expectedDatacenter is the datacenter the service must be stored on
+type expectedDatacenter struct {
+	Type   string `json:"type"`
+	Region string `json:"region"`
+}
+
+// Name describes a step the way the catalog lists it
+func (s scenarioStep) Name() string {
+	switch {
+	case s.Apply != "":
+		return "service apply " + s.Apply
+	case s.Destroy:
+		return "service destroy"
+	}
+	return strings.Join(s.Command, " ")
+}
+
+// catalogService is the service the catalog commands work on, the one the
+// definitions are named after
+const catalogService = "my_service"
+
+// catalogCommand returns the cli command a tester runs for a step, on
+// catalogService
+func (s scenarioStep) catalogCommand() string {
+	name := s.Name()
+	if s.Destroy {
+		name += " {service}"
+	}
+	return "ernest " + expandVars(name, map[string]string{"service": catalogService}).(string)
+}
+
+// scenariosPath returns the path of the scenarios directory
+func scenariosPath() string {
+	_, filename, _, _ := runtime.Caller(0)
+	return path.Join(path.Dir(filename), "scenarios")
+}
+
+// loadScenarios reads the manifests found on paths, directories being read
+// in name order
+func loadScenarios(paths ...string) ([]scenario, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		matches, err := filepath.Glob(path.Join(p, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+
+	var scenarios []scenario
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		s := scenario{}
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("%s: %s", f, err.Error())
+		}
+		if s.Name == "" {
+			s.Name = strings.TrimSuffix(path.Base(f), ".json")
+		}
+		if s.Provider == "" {
+			s.Provider = "vcloud"
+		}
+		for _, step := range s.Steps {
+			if _, ok := orderGraphs[step.Expect.Follows]; step.Expect.Follows != "" && !ok {
+				return nil, fmt.Errorf("%s: unknown order %s", f, step.Expect.Follows)
+			}
+		}
+		scenarios = append(scenarios, s)
+	}
+
+	return scenarios, nil
+}
+
+// datacenter returns the datacenter the definitions of s are applied on
+func (s scenario) datacenter() string {
+	if s.Provider == "aws" {
+		return "fakeaws"
+	}
+	return "fake"
+}
+
+// resourceIDs returns the mapping fields the connectors of the provider of
+// s fill in, by component type
+func (s scenario) resourceIDs() map[string]string {
+	if s.Provider == "aws" {
+		return awsResourceIDs
+	}
+	return vcloudResourceIDs
+}
+
+// exec runs the command of a step on service, returning its output and the
+// definition file it applied if any
+func (s scenario) exec(step scenarioStep, service string) (string, string) {
+	var o, f string
+	switch {
+	case step.Apply != "":
+		f = getDefinitionPathOn(step.Apply, service, s.datacenter())
+		o, _ = ernest("service", "apply", f)
+	case step.Destroy:
+		o, _ = ernest("service", "destroy", "--force", service)
+	default:
+		var args []string
+		for _, a := range step.Command {
+			args = append(args, strings.Replace(a, "{service}", service, -1))
+		}
+		o, _ = ernest(args...)
+	}
+	return o, f
+}
+
+// subjects returns the subjects a step must record to check its
+// expectations, leaving out the ones another of them already covers
+func (step scenarioStep) subjects() []string {
+	var all []string
+	for subject := range step.Expect.Events {
+		all = append(all, subject)
+	}
+	for _, m := range step.Expect.Messages {
+		all = append(all, m.Subject)
+	}
+	for _, pair := range step.Expect.Before {
+		all = append(all, pair[0], pair[1])
+	}
+	for subject, deps := range orderGraphs[step.Expect.Follows] {
+		all = append(all, subject)
+		all = append(all, deps...)
+	}
+	sort.Strings(all)
+
+	var subjects []string
+	for i, subject := range all {
+		covered := false
+		for j, other := range all {
+			if (other != subject && subjectMatches(other, subject)) || (other == subject && j < i) {
+				covered = true
+			}
+		}
+		if !covered {
+			subjects = append(subjects, subject)
+		}
+	}
+	return subjects
+}
+
+// messageVars returns the placeholder values of the expected messages of a
+// step run on service
+func messageVars(service string) map[string]string {
+	return map[string]string{
+		"service":            service,
+		"default_usr":        default_usr,
+		"default_pwd":        default_pwd,
+		"default_org":        default_org,
+		"default_aws_secret": default_aws_secret,
+		"salt_user":          salt.User,
+		"salt_password":      salt.Password,
+	}
+}
+
+// runStep runs a step of a scenario on service and returns every unmet
+// expectation, ips being the ledger of the service when s checks its
+// addresses
+func (s scenario) runStep(step scenarioStep, service string, ips *ipLedger) []string {
+	subjects := step.subjects()
+	tl, err := recordTimeline(subjects...)
+	if err != nil {
+		return []string{"could not record " + strings.Join(subjects, ", ") + ": " + err.Error()}
+	}
+	defer tl.Stop()
+
+	builds, _ := findServices(service)
+
+	o, applied := s.exec(step, service)
+
+	counts := make(map[string]int)
+	for subject, count := range step.Expect.Events {
+		counts[subject] = count
+	}
+	for _, m := range step.Expect.Messages {
+		expected := 0
+		for _, other := range step.Expect.Messages {
+			if other.Subject == m.Subject {
+				expected++
+			}
+		}
+		if expected > counts[m.Subject] {
+			counts[m.Subject] = expected
+		}
+	}
+	var waited []string
+	for subject := range counts {
+		waited = append(waited, subject)
+	}
+	sort.Strings(waited)
+
+	var errs []string
+	for _, subject := range waited {
+		count := counts[subject]
+		if tl.Wait(subject, count) != nil {
+			errs = append(errs, fmt.Sprintf("expected %d %s messages but got %d", count, subject, len(tl.Matching(subject))))
+		}
+	}
+
+	if len(step.Expect.Messages) > 0 {
+		errs = append(errs, matchMessages(step.Expect.Messages, tl.Entries(), messageVars(service))...)
+	}
+	for _, pair := range step.Expect.Before {
+		if err := tl.Before(pair[0], pair[1]); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if step.Expect.Follows != "" {
+		errs = append(errs, tl.Follows(orderGraphs[step.Expect.Follows])...)
+	}
+
+	if step.Expect.Stored {
+		definition, err := ioutil.ReadFile(applied)
+		if err != nil {
+			errs = append(errs, "could not read the applied definition: "+err.Error())
+		}
+		stored, storedErrs := storedBuildErrors(service, string(definition), len(builds)+1, s.resourceIDs())
+		errs = append(errs, storedErrs...)
+		errs = append(errs, s.storedErrors(step.Expect, stored)...)
+	}
+
+	if step.Expect.Status != "" {
+		svc, err := getService(service)
+		if err != nil {
+			errs = append(errs, "service not found: "+err.Error())
+		} else if svc.Status != step.Expect.Status {
+			errs = append(errs, "expected service to be "+step.Expect.Status+" but it is "+svc.Status)
+		}
+	}
+
+	if ips != nil {
+		switch {
+		case step.Apply != "":
+			errs = append(errs, ips.Applied(step.Apply)...)
+		case step.Destroy:
+			if err := ips.Sync(); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	// the http driver prints the api responses, so what the cli prints is
+	// only checked with the cli driver
+	if driver == httpDriver {
+		return errs
+	}
+
+	vars := map[string]string{"service": service}
+	if step.Expect.Info != nil {
+		errs = append(errs, infoErrors(*step.Expect.Info, parseServiceInfo(o), vars)...)
+	}
+	if step.Expect.Output != "" {
+		errs = append(errs, matchOutput(o, step.Expect.Output, vars)...)
+	}
+	if step.Expect.Golden != "" {
+		errs = append(errs, matchGolden(step.Expect.Golden, o, vars)...)
+	}
+
+	return errs
+}
+
+// infoErrors checks the parsed output of service info against the expected
+// one, placeholders being replaced by their values
+func infoErrors(e expectedInfo, info serviceInfo, vars map[string]string) []string {
+	var errs []string
+	expand := func(s string) string { return expandVars(s, vars).(string) }
+
+	if e.Name != "" && info.Name != expand(e.Name) {
+		errs = append(errs, fmt.Sprintf("expected service info to show name %q but got %q", expand(e.Name), info.Name))
+	}
+	if e.Status != "" && info.Status != e.Status {
+		errs = append(errs, fmt.Sprintf("expected service info to show status %q but got %q", e.Status, info.Status))
+	}
+	for _, expected := range e.Networks {
+		expected = networkRecord{Name: expand(expected.Name), Subnet: expand(expected.Subnet)}
+		found := false
+		for _, n := range info.Networks {
+			found = found || n == expected
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("expected service info to show network %s on %s but got %v", expected.Name, expected.Subnet, info.Networks))
+		}
+	}
+
+	return errs
+}
+
+// storedErrors checks the mapping values and the datacenter expected for
+// the stored build of a step
+func (s scenario) storedErrors(e expectation, stored storedService) []string {
+	var errs []string
+
+	if len(e.Mapping) > 0 {
+		m, err := getServiceMapping(stored.ID)
+		if err != nil {
+			return []string{"mapping not found: " + err.Error()}
+		}
+		var fields []string
+		for f := range e.Mapping {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		for _, f := range fields {
+			parts := strings.SplitN(f, ".", 2)
+			if len(parts) != 2 {
+				errs = append(errs, "mapping fields are given as type.field, not "+f)
+				continue
+			}
+			got := m.Field(parts[0], parts[1])
+			if strings.Join(got, ",") != strings.Join(e.Mapping[f], ",") {
+				errs = append(errs, fmt.Sprintf("expected %s to be stored as %v but got %v", f, e.Mapping[f], got))
+			}
+		}
+	}
+
+	if e.Datacenter != nil {
+		d, err := getDatacenter(s.datacenter())
+		switch {
+		case err != nil:
+			errs = append(errs, "datacenter not found: "+err.Error())
+		case stored.DatacenterID != d.ID:
+			errs = append(errs, "expected the service to be stored on "+s.datacenter())
+		case d.Type != e.Datacenter.Type || d.Region != e.Datacenter.Region:
+			errs = append(errs, fmt.Sprintf("expected %s to be a %s datacenter on %s but it is a %s one on %s", s.datacenter(), e.Datacenter.Type, e.Datacenter.Region, d.Type, d.Region))
+		}
+	}
+
+	return errs
+}
+
+// runOptions select the steps of a scenario run
+type runOptions struct {
+	Filter tagFilter
+	// Start is the index of the first step to run
+	Start int
+	// Ready is set when the service already holds the state Start starts
+	// from
+	Ready bool
+}
+
+// run runs the steps of a scenario selected by opts on service, reporting
+// the unmet expectations of each of them and checkpointing the service
+// state after them, a checkpoint that cannot be saved failing its step. A
+// selected step following skipped ones gets the service state it needs by
+// replaying its prerequisites first, with no expectations
+func (s scenario) run(service string, opts runOptions, report func(step scenarioStep, errs []string)) {
+	if !s.selected(opts.Filter) {
+		return
+	}
+
+	basicSetup(s.Provider)
+
+	// a ledger only knows the addresses of the instances it saw created, so
+	// none is kept when the service already holds earlier steps
+	var ips *ipLedger
+	if s.IPs && !opts.Ready {
+		l, err := newIPLedger(s.datacenter(), service)
+		if err != nil {
+			report(s.Steps[opts.Start], []string{"could not follow the instance addresses: " + err.Error()})
+			return
+		}
+		defer l.Stop()
+		for name, subnet := range s.Networks {
+			l.Network(name, subnet)
+		}
+		ips = l
+	}
+
+	ran := opts.Start == 0 || opts.Ready
+	for i, step := range s.Steps {
+		if i < opts.Start {
+			continue
+		}
+		if !opts.Filter.selects(s.stepTags(step)) {
+			ran = false
+			continue
+		}
+		if !ran {
+			for _, prev := range s.prerequisites(i) {
+				s.exec(prev, service)
+				if ips != nil {
+					ips.Sync()
+				}
+			}
+		}
+		errs := s.runStep(step, service, ips)
+		if err := newCheckpoint(s, i, service, errs).save(); err != nil {
+			errs = append(errs, "could not save the checkpoint: "+err.Error())
+		}
+		report(step, errs)
+		ran = true
+	}
+}
+
+// prerequisites returns the steps to replay on a new service for it to hold
+// the state the step i of a scenario starts from: the last apply before it,
+// followed by the destroy after it when the service was destroyed since
+func (s scenario) prerequisites(i int) []scenarioStep {
+	var destroy []scenarioStep
+	for j := i - 1; j >= 0; j-- {
+		switch {
+		case s.Steps[j].Apply != "":
+			return append([]scenarioStep{s.Steps[j]}, destroy...)
+		case s.Steps[j].Destroy && destroy == nil:
+			destroy = []scenarioStep{s.Steps[j]}
+		}
+	}
+	return nil
+}
+
+// stepTags returns the tags of a step of s, its own and the scenario ones
+func (s scenario) stepTags(step scenarioStep) []string {
+	return append(append([]string{}, s.Tags...), step.Tags...)
+}
+
+// selected reports if filter selects any step of s
+func (s scenario) selected(filter tagFilter) bool {
+	for _, step := range s.Steps {
+		if filter.selects(s.stepTags(step)) {
+			return true
+		}
+	}
+	return false
+}
+
+// tagFilter selects the steps holding any of Tags, or every step when no
+// tag is given, and none of Skip
+type tagFilter struct {
+	Tags []string
+	Skip []string
+}
+
+// parseTags reads a comma separated list of tags
+func parseTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// selects reports if a step with the given tags passes the filter
+func (f tagFilter) selects(tags []string) bool {
+	for _, t := range tags {
+		if hasTag(f.Skip, t) {
+			return false
+		}
+	}
+	if len(f.Tags) == 0 {
+		return true
+	}
+	for _, t := range tags {
+		if hasTag(f.Tags, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTag reports if tags holds tag
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// catalogMarker precedes the fenced block of a catalog template holding the
+// steps of a scenario
+var catalogMarker = regexp.MustCompile(`^<!-- scenario: (\S+) -->$`)
+
+// catalogPath returns the path of the commands catalog
+func catalogPath() string {
+	return path.Join(path.Dir(scenariosPath()), "definitions", "commands.md")
+}
+
+// renderCatalog writes a catalog template with the fenced block following
+// each scenario marker filled with the steps of that scenario, as the
+// commands a tester would run on catalogService. Every scenario needs a
+// marker, so none is left out of the catalog
+func renderCatalog(w io.Writer, template string, scenarios []scenario) error {
+	byName := make(map[string]scenario, len(scenarios))
+	for _, s := range scenarios {
+		byName[s.Name] = s
+	}
+
+	lines := strings.Split(template, "\n")
+	rendered := make(map[string]bool)
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		out = append(out, lines[i])
+		m := catalogMarker.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		s, ok := byName[m[1]]
+		if !ok {
+			return fmt.Errorf("line %d: unknown scenario %s", i+1, m[1])
+		}
+		if i+1 >= len(lines) || lines[i+1] != "```" {
+			return fmt.Errorf("line %d: the %s marker is not followed by a ``` block", i+1, m[1])
+		}
+		end := i + 2
+		for end < len(lines) && lines[end] != "```" {
+			end++
+		}
+		if end == len(lines) {
+			return fmt.Errorf("line %d: the %s block is not closed", i+2, m[1])
+		}
+
+		out = append(out, "```")
+		for _, step := range s.Steps {
+			line := step.catalogCommand()
+			if step.Comment != "" {
+				line += " # " + step.Comment
+			}
+			out = append(out, line)
+		}
+		out = append(out, "```")
+		rendered[s.Name] = true
+		i = end
+	}
+
+	for _, s := range scenarios {
+		if !rendered[s.Name] {
+			return fmt.Errorf("%s has no marker on the catalog", s.Name)
+		}
+	}
+
+	_, err := io.WriteString(w, strings.Join(out, "\n"))
+	return err
+}
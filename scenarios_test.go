@@ -0,0 +1,168 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// stepResult is a step of a scenario along with its unmet expectations
+type stepResult struct {
+	Step scenarioStep
+	Errs []string
+}
+
+func TestScenarios(t *testing.T) {
+	scenarios, err := loadScenarios(scenariosPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter := tagFilter{
+		Tags: parseTags(os.Getenv("UAT_TAGS")),
+		Skip: parseTags(os.Getenv("UAT_SKIP_TAGS")),
+	}
+	from := os.Getenv("UAT_RESUME_FROM")
+
+	for _, s := range scenarios {
+		if !s.selected(filter) || (from != "" && !s.hasStep(from)) {
+			continue
+		}
+		service := ""
+		opts := runOptions{}
+		if from != "" {
+			if opts, service, err = s.resume(from, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if service == "" {
+			service = strings.Replace(s.Name, "-", "", -1) + strconv.Itoa(rand.Intn(9999999))
+		}
+		opts.Filter = filter
+
+		// steps are run once up front, as convey runs its blocks once per
+		// assertion path
+		var results []stepResult
+		s.run(service, opts, func(step scenarioStep, errs []string) {
+			results = append(results, stepResult{step, errs})
+		})
+
+		Convey("Given I run the "+s.Name+" scenario", t, func() {
+			for _, r := range results {
+				r := r
+				Convey("When I run "+r.Step.Name(), func() {
+					Convey("Then every expectation should be met", func() {
+						So(r.Errs, ShouldBeEmpty)
+					})
+					if driver == httpDriver && r.Step.Expect.checksOutput() {
+						SkipConvey("Then its output should match, with the cli driver only", func() {})
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	Convey("Given the scenario manifests", t, func() {
+		scenarios, err := loadScenarios(scenariosPath())
+		So(err, ShouldBeNil)
+
+		Convey("Then the commands catalog should list their steps", func() {
+			catalog, err := ioutil.ReadFile(catalogPath())
+			So(err, ShouldBeNil)
+			var b bytes.Buffer
+			So(renderCatalog(&b, string(catalog), scenarios), ShouldBeNil)
+			So(b.String(), ShouldEqual, string(catalog))
+		})
+	})
+}
+
+func TestRenderCatalog(t *testing.T) {
+	scenarios := []scenario{{Name: "vse", Steps: []scenarioStep{
+		{Apply: "vse1.yml", Comment: "initial creation"},
+		{Command: []string{"service", "list"}},
+		{Command: []string{"service", "info", "{service}"}},
+		{Destroy: true},
+	}}}
+
+	cases := []struct {
+		description string
+		template    string
+		rendered    string
+		err         string
+	}{
+		{
+			"a template with a marker",
+			"## Test VSE Case\n\nNon-Bootstrapped:\n<!-- scenario: vse -->\n```\nernest service apply old.yml\n```\n\nRun both tests in parallel.\n",
+			"## Test VSE Case\n\nNon-Bootstrapped:\n<!-- scenario: vse -->\n```\nernest service apply vse1.yml # initial creation\nernest service list\nernest service info my_service\nernest service destroy my_service\n```\n\nRun both tests in parallel.\n",
+			"",
+		},
+		{"a template missing a scenario", "## Test VSE Case\n", "", "vse has no marker on the catalog"},
+		{"a template with an unknown scenario", "<!-- scenario: vse -->\n```\n```\n<!-- scenario: aws -->\n```\n```\n", "", "line 4: unknown scenario aws"},
+		{"a marker with no block", "<!-- scenario: vse -->\nernest service list\n", "", "line 1: the vse marker is not followed by a ``` block"},
+		{"a block not closed", "<!-- scenario: vse -->\n```\nernest service list\n", "", "line 2: the vse block is not closed"},
+	}
+
+	Convey("Given a catalog template", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When I render "+c.description, func() {
+				var b bytes.Buffer
+				err := renderCatalog(&b, c.template, scenarios)
+
+				Convey("Then the marked blocks should hold the scenario steps", func() {
+					if c.err != "" {
+						So(err, ShouldNotBeNil)
+						So(err.Error(), ShouldEqual, c.err)
+						return
+					}
+					So(err, ShouldBeNil)
+					So(b.String(), ShouldEqual, c.rendered)
+				})
+			})
+		}
+	})
+}
+
+func TestInfoErrors(t *testing.T) {
+	vars := map[string]string{"service": "r3vse1"}
+	db := networkRecord{Name: "fake-{service}-db", Subnet: "10.2.0.0/24"}
+
+	cases := []struct {
+		description string
+		expected    expectedInfo
+		errs        []string
+	}{
+		{"its name, status and one of its networks", expectedInfo{Name: "{service}", Status: "done", Networks: []networkRecord{db}}, nil},
+		{"another status", expectedInfo{Status: "errored"}, []string{`expected service info to show status "errored" but got "done"`}},
+		{"a network on another subnet", expectedInfo{Networks: []networkRecord{{Name: "fake-{service}-db", Subnet: "10.3.0.0/24"}}}, []string{
+			"expected service info to show network fake-r3vse1-db on 10.3.0.0/24 but got [{fake-r3vse1-db 10.2.0.0/24} {fake-r3vse1-web 10.1.0.0/24}]",
+		}},
+	}
+
+	Convey("Given the output of service info", t, func() {
+		info := parseServiceInfo(fixture("service_info.txt"))
+
+		for _, c := range cases {
+			c := c
+			Convey("When I expect "+c.description, func() {
+				errs := infoErrors(c.expected, info, vars)
+
+				Convey("Then only what it does not show should be reported", func() {
+					So(errs, ShouldResemble, c.errs)
+				})
+			})
+		}
+	})
+}
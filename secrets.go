@@ -0,0 +1,74 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// secrets maps every known secret value to the name it is reported as
+var secrets = make(map[string]string)
+var secretsLock sync.Mutex
+
+// registerSecret adds a value that must never leak, empty values are ignored
+func registerSecret(name, value string) {
+	if value == "" {
+		return
+	}
+
+	secretsLock.Lock()
+	defer secretsLock.Unlock()
+	if _, ok := secrets[value]; !ok {
+		secrets[value] = name
+	}
+}
+
+// registerDefaultSecrets registers the credentials the harness itself sets
+// up and the comma separated values configured on UAT_SECRETS
+func registerDefaultSecrets() {
+	registerSecret("admin password", admin_pwd)
+	registerSecret("user password", default_pwd)
+	registerSecret("aws secret", default_aws_secret)
+
+	for _, v := range strings.Split(os.Getenv("UAT_SECRETS"), ",") {
+		registerSecret("configured secret", strings.TrimSpace(v))
+	}
+}
+
+// knownSecrets returns the registered secret values, longest first so
+// callers matching them find the most specific one
+func knownSecrets() []string {
+	secretsLock.Lock()
+	defer secretsLock.Unlock()
+
+	values := make([]string, 0, len(secrets))
+	for v := range secrets {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+
+	return values
+}
+
+// secretName returns the name a secret value was registered with
+func secretName(value string) string {
+	secretsLock.Lock()
+	defer secretsLock.Unlock()
+	return secrets[value]
+}
+
+// secretPattern matches a secret value not being part of a longer word
+func secretPattern(value string) *regexp.Regexp {
+	return regexp.MustCompile(`(^|\W)` + regexp.QuoteMeta(value) + `(\W|$)`)
+}
@@ -0,0 +1,135 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type stateCase struct {
+	description string
+	status      string
+	destroyed   bool
+	command     string
+	accepted    bool
+	message     string
+	stored      string
+}
+
+// inProgressMessage is what the api answers to changes on a service whose
+// build is in progress
+const inProgressMessage = "Your service process is 'in progress' if your're sure you want to fix it please reset it first"
+
+var stateCases = []stateCase{
+	{description: "I apply an in progress service", status: "in_progress", command: "apply", message: inProgressMessage, stored: "in_progress"},
+	{description: "I destroy an in progress service", status: "in_progress", command: "destroy", message: inProgressMessage, stored: "in_progress"},
+	{description: "I re-apply a done service", status: "done", command: "apply", accepted: true, message: "Environment creation requested", stored: "done"},
+	{description: "I destroy an errored service", status: "errored", command: "destroy", accepted: true, message: "Environment deleted"},
+	{description: "I apply a destroyed service", destroyed: true, command: "apply", accepted: true, message: "Environment creation requested", stored: "done"},
+}
+
+func TestServiceStates(t *testing.T) {
+	var service = "state"
+	type ServiceEvent struct {
+		ID string `json:"id"`
+	}
+
+	service = service + strconv.Itoa(rand.Intn(9999999))
+
+	createSub := make(chan *nats.Msg, 1)
+	deleteSub := make(chan *nats.Msg, 1)
+	basicSetup("vcloud")
+
+	Convey("Given I have a configured ernest instance", t, func() {
+		for i, c := range stateCases {
+			name := service + "x" + strconv.Itoa(i)
+			c := c
+
+			Convey("When "+c.description, func() {
+				f := getDefinitionPath("inst1.yml", name)
+				ernest("service", "apply", f)
+
+				previous, _ := getService(name)
+				if c.destroyed {
+					ernest("service", "destroy", "--force", name)
+				}
+				if c.status != "" {
+					setServiceStatus(previous.ID, c.status)
+				}
+
+				csub, _ := n.ChanSubscribe("service.create", createSub)
+				dsub, _ := n.ChanSubscribe("service.delete", deleteSub)
+
+				var o string
+				if c.command == "apply" {
+					f = getDefinitionPath("inst1.yml", name)
+					o, _ = ernest("service", "apply", f)
+				} else {
+					o, _ = ernest("service", "destroy", "--force", name)
+				}
+
+				if c.accepted {
+					Convey("Then it should be accepted", func() {
+						Info("And the cli should say "+c.message, " ", 8)
+						So(o, ShouldContainSubstring, c.message)
+
+						ch := createSub
+						if c.command == "destroy" {
+							ch = deleteSub
+						}
+
+						event := ServiceEvent{}
+						msg, err := waitMsg(ch)
+						So(err, ShouldBeNil)
+						json.Unmarshal(msg.Data, &event)
+
+						if c.command == "apply" {
+							Info("And it should create a new service build", " ", 8)
+							So(previous.ID, ShouldNotEqual, "")
+							So(event.ID, ShouldNotEqual, previous.ID)
+						}
+
+						s, err := getService(name)
+						if c.stored == "" {
+							Info("And the service should be removed from the store", " ", 8)
+							So(s.ID, ShouldEqual, "")
+						} else {
+							Info("And the service should be stored as "+c.stored, " ", 8)
+							So(err, ShouldBeNil)
+							So(s.Status, ShouldEqual, c.stored)
+						}
+					})
+				} else {
+					Convey("Then it should be rejected", func() {
+						Info("And the cli should say the service is in progress", " ", 8)
+						So(o, ShouldContainSubstring, c.message)
+
+						_, err := waitMsgTime(createSub, 2*time.Second)
+						So(err, ShouldNotBeNil)
+						_, err = waitMsgTime(deleteSub, time.Millisecond)
+						So(err, ShouldNotBeNil)
+
+						Info("And the service should still be stored as "+c.stored, " ", 8)
+						s, err := getService(name)
+						So(err, ShouldBeNil)
+						So(previous.ID, ShouldNotEqual, "")
+						So(s.ID, ShouldEqual, previous.ID)
+						So(s.Status, ShouldEqual, c.stored)
+					})
+				}
+
+				csub.Unsubscribe()
+				dsub.Unsubscribe()
+			})
+		}
+	})
+}
@@ -0,0 +1,366 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats"
+)
+
+// awsResourceIDs are the fields the aws connectors fill in on the mapping,
+// by component type
+var awsResourceIDs = map[string]string{
+	"network":  "network_aws_id",
+	"firewall": "security_group_aws_id",
+	"instance": "instance_aws_id",
+	"nat":      "nat_gateway_aws_id",
+	"elb":      "elb_dns_name",
+}
+
+// vcloudResourceIDs are the fields the vcloud connectors fill in on the
+// mapping, by component type
+var vcloudResourceIDs = map[string]string{
+	"router":   "id",
+	"network":  "id",
+	"instance": "id",
+}
+
+// versionLayouts are the formats the service store gives versions in
+var versionLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05"}
+
+type storedService struct {
+	ID           string `json:"id"`
+	GroupID      int    `json:"group_id"`
+	DatacenterID int    `json:"datacenter_id"`
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Version      string `json:"version"`
+	Status       string `json:"status"`
+	Definition   string `json:"definition"`
+}
+
+type storedDatacenter struct {
+	ID              int    `json:"id"`
+	GroupID         int    `json:"group_id"`
+	Name            string `json:"name"`
+	Type            string `json:"type"`
+	Region          string `json:"region"`
+	Username        string `json:"username"`
+	Password        string `json:"password"`
+	VCloudURL       string `json:"vcloud_url"`
+	VseURL          string `json:"vse_url"`
+	ExternalNetwork string `json:"external_network"`
+	Token           string `json:"token"`
+	Secret          string `json:"secret"`
+}
+
+type storedMapping struct {
+	Components []map[string]interface{} `json:"components"`
+}
+
+// harnessInbox prefixes the inboxes the harness gets its own replies on, so
+// the credentials the stores answer it with are told from leaked ones
+const harnessInbox = "_INBOX.uat."
+
+// request publishes a request of the harness and waits for its reply on an
+// inbox under harnessInbox
+func request(subject string, data []byte, timeout time.Duration) (*nats.Msg, error) {
+	inbox := harnessInbox + strconv.FormatInt(rand.Int63(), 36)
+	sub, err := n.SubscribeSync(inbox)
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	if err := n.PublishRequest(subject, inbox, data); err != nil {
+		return nil, err
+	}
+	return sub.NextMsg(timeout)
+}
+
+func storeRequest(subject string, query interface{}, v interface{}) error {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return err
+	}
+
+	msg, err := request(subject, body, time.Second)
+	if err != nil {
+		return err
+	}
+
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(msg.Data, &e) == nil && e.Error != "" {
+		return errors.New(e.Error)
+	}
+
+	return json.Unmarshal(msg.Data, v)
+}
+
+// getService returns the latest build of a service from the service store
+func getService(name string) (storedService, error) {
+	s := storedService{}
+	err := storeRequest("service.get", map[string]string{"name": name}, &s)
+	return s, err
+}
+
+// findServices returns every build of a service, the latest one first
+func findServices(name string) ([]storedService, error) {
+	var builds []storedService
+	err := storeRequest("service.find", map[string]string{"name": name}, &builds)
+	sort.SliceStable(builds, func(i, j int) bool { return newerVersion(builds[i].Version, builds[j].Version) })
+	return builds, err
+}
+
+// newerVersion reports if version a comes after version b, versions being
+// compared as times or numbers when both of them read as such
+func newerVersion(a, b string) bool {
+	for _, layout := range versionLayouts {
+		ta, errA := time.Parse(layout, a)
+		tb, errB := time.Parse(layout, b)
+		if errA == nil && errB == nil {
+			return ta.After(tb)
+		}
+	}
+
+	na, errA := strconv.ParseFloat(a, 64)
+	nb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return na > nb
+	}
+
+	return a > b
+}
+
+// waitStoredService waits for the latest of builds builds of a service to
+// leave the in progress status
+func waitStoredService(service string, builds int) {
+	timeout := time.After(time.Millisecond * 10000)
+	for {
+		all, err := findServices(service)
+		if err == nil && len(all) >= builds && all[0].Status != "in_progress" {
+			return
+		}
+		select {
+		case <-timeout:
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// storedBuildErrors verifies the service store holds the build created by
+// the last apply of definition as done, on top of builds-1 previous ones and
+// newer than them, along with the ids the connectors returned for its
+// resources. It returns the latest build and every unmet expectation
+func storedBuildErrors(service, definition string, builds int, ids map[string]string) (storedService, []string) {
+	waitStoredService(service, builds)
+
+	s, err := getService(service)
+	if err != nil {
+		return s, []string{"service not found: " + err.Error()}
+	}
+
+	var errs []string
+	if s.Status != "done" {
+		errs = append(errs, "expected the stored service to be done but it is "+s.Status)
+	}
+	errs = append(errs, definitionErrors(definition, s.Definition)...)
+	if s.Version == "" {
+		errs = append(errs, "the stored service has no version")
+	}
+
+	all, err := findServices(service)
+	if err != nil {
+		return s, append(errs, "builds not found: "+err.Error())
+	}
+	if len(all) != builds {
+		errs = append(errs, fmt.Sprintf("expected %d stored builds but got %d", builds, len(all)))
+	}
+	if len(all) > 0 && all[0].ID != s.ID {
+		errs = append(errs, "the latest stored build is "+all[0].ID+" instead of "+s.ID)
+	}
+	if len(all) > 1 && !newerVersion(all[0].Version, all[1].Version) {
+		errs = append(errs, "the latest stored build is not newer than the one before")
+	}
+
+	m, err := getServiceMapping(s.ID)
+	if err != nil {
+		return s, append(errs, "mapping not found: "+err.Error())
+	}
+	var types []string
+	for t := range ids {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		for _, name := range m.Missing(t, ids[t]) {
+			errs = append(errs, fmt.Sprintf("%s %s has no %s stored", t, name, ids[t]))
+		}
+	}
+
+	return s, errs
+}
+
+// definitionErrors compares a stored definition field by field with the
+// applied one
+func definitionErrors(applied, stored string) []string {
+	want := definitionFields(applied)
+	got := definitionFields(stored)
+
+	var paths []string
+	for p := range want {
+		paths = append(paths, p)
+	}
+	for p := range got {
+		if _, ok := want[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var errs []string
+	for _, p := range paths {
+		w, applied := want[p]
+		g, stored := got[p]
+		switch {
+		case !stored:
+			errs = append(errs, "the stored definition has no "+p)
+		case !applied:
+			errs = append(errs, "the stored definition has "+p+" "+g+" which was not applied")
+		case w != g:
+			errs = append(errs, "the stored definition has "+p+" "+g+" instead of "+w)
+		}
+	}
+	return errs
+}
+
+// definitionFields flattens a yaml definition into the value of every
+// field by path, list items being numbered from 0 as in instances[1].name
+func definitionFields(definition string) map[string]string {
+	type parent struct {
+		indent int
+		path   string
+		item   bool
+	}
+
+	fields := make(map[string]string)
+	items := make(map[string]int)
+	var parents []parent
+
+	scanner := bufio.NewScanner(strings.NewReader(definition))
+	for scanner.Scan() {
+		line := scanner.Text()
+		t := strings.TrimSpace(line)
+		if t == "" || strings.HasPrefix(t, "#") || t == "---" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		item := t == "-" || strings.HasPrefix(t, "- ")
+
+		// a list may be indented as much as its key
+		for len(parents) > 0 {
+			top := parents[len(parents)-1]
+			if top.indent < indent || (item && top.indent == indent && !top.item) {
+				break
+			}
+			parents = parents[:len(parents)-1]
+		}
+		path := ""
+		if len(parents) > 0 {
+			path = parents[len(parents)-1].path
+		}
+
+		if item {
+			list := path
+			path = fmt.Sprintf("%s[%d]", list, items[list])
+			items[list]++
+			parents = append(parents, parent{indent: indent, path: path, item: true})
+			t = strings.TrimSpace(strings.TrimPrefix(t, "-"))
+			indent += 2
+			if t == "" {
+				continue
+			}
+			if !strings.Contains(t, ":") {
+				fields[path] = strings.Trim(t, `'"`)
+				continue
+			}
+		}
+
+		key, value := yamlField(t)
+		if path != "" {
+			key = path + "." + key
+		}
+		if value == "" {
+			parents = append(parents, parent{indent: indent, path: key})
+			continue
+		}
+		fields[key] = value
+	}
+
+	return fields
+}
+
+// getDatacenter returns a datacenter from the datacenter store
+func getDatacenter(name string) (storedDatacenter, error) {
+	d := storedDatacenter{}
+	err := storeRequest("datacenter.get", map[string]string{"name": name}, &d)
+	return d, err
+}
+
+// getServiceMapping returns the mapping stored for a service build, with
+// the fields the connectors have filled in
+func getServiceMapping(id string) (storedMapping, error) {
+	m := storedMapping{}
+	err := storeRequest("service.get.mapping", map[string]string{"id": id}, &m)
+	return m, err
+}
+
+// Field returns the value of field on every component of a type
+func (m storedMapping) Field(componentType, field string) []string {
+	var values []string
+	for _, c := range m.Components {
+		if c["_component"] != componentType {
+			continue
+		}
+		if v, ok := c[field].(string); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Missing returns the name of every component of a type with no value on
+// field
+func (m storedMapping) Missing(componentType, field string) []string {
+	var missing []string
+	for _, c := range m.Components {
+		if c["_component"] != componentType {
+			continue
+		}
+		if v, _ := c[field].(string); v == "" {
+			name, _ := c["name"].(string)
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// setServiceStatus forces the status of a service build on the store
+func setServiceStatus(id, status string) error {
+	_, err := request("service.set", []byte(`{"id":"`+id+`","status":"`+status+`"}`), time.Second)
+	return err
+}
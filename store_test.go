@@ -0,0 +1,115 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewerVersion(t *testing.T) {
+	cases := []struct {
+		a, b  string
+		newer bool
+	}{
+		{"2016-10-19T10:00:01Z", "2016-10-19T10:00:00Z", true},
+		{"2016-10-19T10:00:00Z", "2016-10-19T10:00:01Z", false},
+		{"2016-10-19T10:00:00.5+02:00", "2016-10-19T09:00:00Z", false},
+		{"2016-10-19 10:00:10", "2016-10-19 10:00:09", true},
+		{"10", "9", true},
+		{"9", "10", false},
+		{"100", "99", true},
+	}
+
+	Convey("Given two service versions", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When I compare "+c.a+" with "+c.b, func() {
+				Convey("Then the latest one should come first", func() {
+					So(newerVersion(c.a, c.b), ShouldEqual, c.newer)
+				})
+			})
+		}
+	})
+}
+
+func TestMappingMissing(t *testing.T) {
+	m := storedMapping{Components: []map[string]interface{}{
+		{"_component": "instance", "name": "web-1", "instance_aws_id": "i-1"},
+		{"_component": "instance", "name": "web-2", "instance_aws_id": ""},
+		{"_component": "instance", "name": "web-3"},
+		{"_component": "network", "name": "web"},
+	}}
+
+	Convey("Given a stored mapping", t, func() {
+		Convey("When some instances have no id", func() {
+			Convey("Then they should be reported as missing", func() {
+				So(m.Missing("instance", "instance_aws_id"), ShouldResemble, []string{"web-2", "web-3"})
+				So(m.Missing("elb", "elb_dns_name"), ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func TestDefinitionErrors(t *testing.T) {
+	applied := `---
+name: my_service
+datacenter: fake
+instances:
+  - name: web
+    cpus: 1
+    networks:
+      - web
+  - name: db
+    count: 2
+`
+
+	cases := []struct {
+		description string
+		stored      string
+		errs        []string
+	}{
+		{
+			"the applied definition with its fields reordered and quoted",
+			"datacenter: 'fake'\nname: \"my_service\"\ninstances:\n- cpus: 1\n  name: web\n  networks:\n  - web\n- name: db\n  count: 2\n",
+			nil,
+		},
+		{
+			"a field changed",
+			strings.Replace(applied, "cpus: 1", "cpus: 2", 1),
+			[]string{"the stored definition has instances[0].cpus 2 instead of 1"},
+		},
+		{
+			"a list item left out",
+			strings.Replace(applied, "      - web\n", "", 1),
+			[]string{"the stored definition has no instances[0].networks[0]"},
+		},
+		{
+			"a field that was not applied",
+			applied + "bootstrapping: salt\n",
+			[]string{"the stored definition has bootstrapping salt which was not applied"},
+		},
+		{
+			"the definition of another service",
+			strings.Replace(applied, "name: my_service", "name: other", 1),
+			[]string{"the stored definition has name other instead of my_service"},
+		},
+	}
+
+	Convey("Given an applied definition", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When the store holds "+c.description, func() {
+				errs := definitionErrors(applied, c.stored)
+
+				Convey("Then every field it does not hold as applied should be reported", func() {
+					So(errs, ShouldResemble, c.errs)
+				})
+			})
+		}
+	})
+}
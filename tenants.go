@@ -0,0 +1,72 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+// the messages the cli prints when a user works on what another group owns
+const (
+	serviceNotFound    = "Specified service name does not exist"
+	serviceNameTaken   = "Specified service name already in use by another group"
+	datacenterNotFound = "Specified datacenter does not exist"
+)
+
+// tenant is a user with its own group and vcloud datacenter
+type tenant struct {
+	User       string
+	Password   string
+	Group      string
+	Datacenter string
+	Org        string
+}
+
+// newTenant describes a tenant whose names are all derived from name
+func newTenant(name string) tenant {
+	return tenant{
+		User:       name + "_usr",
+		Password:   name + "_pwd",
+		Group:      name,
+		Datacenter: name + "_dc",
+		Org:        name + "_org",
+	}
+}
+
+// newMember describes another user of the group basicSetup creates, working
+// on its datacenter with the default password
+func newMember(name string) tenant {
+	return tenant{
+		User:       name + "_usr",
+		Password:   default_pwd,
+		Group:      default_group,
+		Datacenter: "fake",
+		Org:        default_org,
+	}
+}
+
+// join creates the member user as admin, adds it to its group and logs it
+// in
+func (t tenant) join() {
+	loginAs(admin_usr, admin_pwd)
+	ernestAs(admin_usr, "user", "create", t.User, t.Password)
+	ernestAs(admin_usr, "group", "add-user", t.User, t.Group)
+	loginAs(t.User, t.Password)
+}
+
+// setup creates the tenant user and group as admin, logs the user in and
+// creates its datacenter
+func (t tenant) setup() {
+	registerSecret(t.User+" password", t.Password)
+
+	loginAs(admin_usr, admin_pwd)
+	ernestAs(admin_usr, "user", "create", t.User, t.Password)
+	ernestAs(admin_usr, "group", "create", t.Group)
+	ernestAs(admin_usr, "group", "add-user", t.User, t.Group)
+
+	loginAs(t.User, t.Password)
+	t.ernest("datacenter", "create", "vcloud", t.Datacenter, "--vcloud-url", "https://myvdc.me.com", "--fake", "--user", t.User, "--password", t.Password, "--org", t.Org, "--vse-url", "http://localhost", "--public-network", "NETWORK")
+}
+
+// ernest runs ernest-cli logged in as the tenant user
+func (t tenant) ernest(cmdArgs ...string) (string, error) {
+	return ernestAs(t.User, cmdArgs...)
+}
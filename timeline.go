@@ -0,0 +1,214 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats"
+)
+
+// vcloudCreateOrder lists, for each connector subject, the subjects that
+// must have been received before it while creating a vcloud service
+var vcloudCreateOrder = map[string][]string{
+	"network.create.*":  {"router.create.*"},
+	"instance.create.*": {"network.create.*"},
+	"firewall.create.*": {"router.create.*"},
+	"nat.create.*":      {"router.create.*"},
+}
+
+// vcloudNoVseCreateOrder lists, for each connector subject, the subjects
+// that must have been received before it while creating a vcloud service on
+// a router that already exists
+var vcloudNoVseCreateOrder = map[string][]string{
+	"instance.create.*": {"network.create.*"},
+}
+
+// awsCreateOrder lists, for each connector subject, the subjects that must
+// have been received before it while creating an aws service
+var awsCreateOrder = map[string][]string{
+	"nat.create.*":      {"network.create.*"},
+	"instance.create.*": {"network.create.*", "firewall.create.*"},
+	"elb.create.*":      {"instance.create.*", "firewall.create.*"},
+}
+
+// vcloudDeleteOrder lists, for each connector subject, the subjects that
+// must have been received before it while destroying a vcloud service
+var vcloudDeleteOrder = map[string][]string{
+	"network.delete.*": {"instance.delete.*"},
+	"router.delete.*":  {"network.delete.*", "firewall.delete.*", "nat.delete.*"},
+}
+
+// awsDeleteOrder lists, for each connector subject, the subjects that must
+// have been received before it while destroying an aws service
+var awsDeleteOrder = map[string][]string{
+	"firewall.delete.*": {"elb.delete.*", "instance.delete.*"},
+	"nat.delete.*":      {"elb.delete.*", "instance.delete.*"},
+	"network.delete.*":  {"nat.delete.*", "instance.delete.*"},
+}
+
+// orderGraphs are the dependency graphs scenario manifests refer to by name
+var orderGraphs = map[string]map[string][]string{
+	"vcloud-create":       vcloudCreateOrder,
+	"vcloud-delete":       vcloudDeleteOrder,
+	"vcloud-novse-create": vcloudNoVseCreateOrder,
+	"aws-create":          awsCreateOrder,
+	"aws-delete":          awsDeleteOrder,
+}
+
+type timelineEntry struct {
+	Subject  string
+	Data     []byte
+	Received time.Time
+}
+
+// timeline records the messages published on a set of subjects in
+// arrival order
+type timeline struct {
+	sync.Mutex
+	entries []timelineEntry
+	subs    []*nats.Subscription
+}
+
+// recordTimeline starts recording every message matching subjects
+func recordTimeline(subjects ...string) (*timeline, error) {
+	t := &timeline{}
+	for _, subject := range subjects {
+		sub, err := n.Subscribe(subject, t.record)
+		if err != nil {
+			t.Stop()
+			return nil, err
+		}
+		t.subs = append(t.subs, sub)
+	}
+	return t, nil
+}
+
+func (t *timeline) record(msg *nats.Msg) {
+	t.Lock()
+	defer t.Unlock()
+	t.entries = append(t.entries, timelineEntry{
+		Subject:  msg.Subject,
+		Data:     msg.Data,
+		Received: time.Now(),
+	})
+}
+
+// Stop ends the recording
+func (t *timeline) Stop() {
+	for _, sub := range t.subs {
+		if sub != nil {
+			sub.Unsubscribe()
+		}
+	}
+}
+
+// Wait blocks until count messages matching pattern have been recorded
+func (t *timeline) Wait(pattern string, count int) error {
+	timeout := time.After(time.Millisecond * 10000)
+	for len(t.Matching(pattern)) < count {
+		select {
+		case <-timeout:
+			return errors.New("timeout")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// Entries returns every recorded message in arrival order
+func (t *timeline) Entries() []timelineEntry {
+	t.Lock()
+	defer t.Unlock()
+	return append([]timelineEntry{}, t.entries...)
+}
+
+// Matching returns the recorded messages whose subject matches pattern
+func (t *timeline) Matching(pattern string) []timelineEntry {
+	var matching []timelineEntry
+	for _, e := range t.Entries() {
+		if subjectMatches(pattern, e.Subject) {
+			matching = append(matching, e)
+		}
+	}
+	return matching
+}
+
+// Before checks every message matching a has been received before the
+// first message matching b
+func (t *timeline) Before(a, b string) error {
+	lastA, firstB := -1, -1
+	for i, e := range t.Entries() {
+		if subjectMatches(a, e.Subject) {
+			lastA = i
+		}
+		if subjectMatches(b, e.Subject) && firstB == -1 {
+			firstB = i
+		}
+	}
+
+	if lastA == -1 {
+		return fmt.Errorf("no %s message received", a)
+	}
+	if firstB == -1 {
+		return fmt.Errorf("no %s message received", b)
+	}
+	if lastA > firstB {
+		return fmt.Errorf("%s received after %s", a, b)
+	}
+	return nil
+}
+
+// Follows checks the recorded messages against a dependency graph, a
+// subject never received leaving its dependencies unchecked and a subject
+// received with no message of a dependency before it being reported
+func (t *timeline) Follows(order map[string][]string) []string {
+	var problems []string
+
+	var subjects []string
+	for s := range order {
+		subjects = append(subjects, s)
+	}
+	sort.Strings(subjects)
+
+	for _, s := range subjects {
+		if len(t.Matching(s)) == 0 {
+			continue
+		}
+		for _, dep := range order[s] {
+			if err := t.Before(dep, s); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+
+	return problems
+}
+
+// subjectMatches reports if subject matches a nats style pattern, where *
+// matches a single token and > the remaining ones
+func subjectMatches(pattern, subject string) bool {
+	p := strings.Split(pattern, ".")
+	s := strings.Split(subject, ".")
+
+	for i, token := range p {
+		if token == ">" {
+			return len(s) > i
+		}
+		if i >= len(s) {
+			return false
+		}
+		if token != "*" && token != s[i] {
+			return false
+		}
+	}
+
+	return len(p) == len(s)
+}
@@ -0,0 +1,62 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at http://mozilla.org/MPL/2.0/. */
+
+package main
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// recorded returns a timeline holding messages on subjects, in order
+func recorded(subjects ...string) *timeline {
+	t := &timeline{}
+	for _, s := range subjects {
+		t.entries = append(t.entries, timelineEntry{Subject: s})
+	}
+	return t
+}
+
+func TestFollows(t *testing.T) {
+	cases := []struct {
+		description string
+		tl          *timeline
+		problems    []string
+	}{
+		{
+			"every dependency received first",
+			recorded("router.create.vcloud-fake", "network.create.vcloud-fake", "firewall.create.vcloud-fake", "nat.create.vcloud-fake", "instance.create.vcloud-fake"),
+			nil,
+		},
+		{
+			"a subject never received",
+			recorded("router.create.vcloud-fake", "network.create.vcloud-fake", "instance.create.vcloud-fake"),
+			nil,
+		},
+		{
+			"a dependency received after",
+			recorded("router.create.vcloud-fake", "instance.create.vcloud-fake", "network.create.vcloud-fake"),
+			[]string{"network.create.* received after instance.create.*"},
+		},
+		{
+			"a dependency never received",
+			recorded("network.create.vcloud-fake", "instance.create.vcloud-fake"),
+			[]string{"no router.create.* message received"},
+		},
+	}
+
+	Convey("Given the messages recorded while creating a vcloud service", t, func() {
+		for _, c := range cases {
+			c := c
+			Convey("When they hold "+c.description, func() {
+				problems := c.tl.Follows(vcloudCreateOrder)
+
+				Convey("Then every message received before a dependency should be reported", func() {
+					So(problems, ShouldResemble, c.problems)
+				})
+			})
+		}
+	})
+}